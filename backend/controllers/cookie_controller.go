@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/DMequanint/url-analyzer-pro/services"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+GetCookieReport handles GET /api/cookies.
+
+Returns one entry per cookie (name, domain, path) set by the latest
+analysis of every URL, with the number of pages setting it and the union
+of its attribute findings. The optional "crawlId" query parameter
+restricts the report to a crawl's pages.
+*/
+func GetCookieReport(c *gin.Context) {
+	report, err := services.CookieReport(c.Query("crawlId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to build cookie report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+GetAllCrawls handles GET /api/crawls.
+
+Returns all crawls with their aggregate statistics, newest first.
+*/
+func GetAllCrawls(c *gin.Context) {
+	var crawls []models.Crawl
+	if err := config.DB.Order("created_at desc").Find(&crawls).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch crawls"})
+		return
+	}
+	c.JSON(http.StatusOK, crawls)
+}
+
+/*
+GetCrawlByID handles GET /api/crawls/:id.
+
+Returns a single crawl with its aggregate statistics, or a 404 if not found.
+*/
+func GetCrawlByID(c *gin.Context) {
+	var crawl models.Crawl
+	if err := config.DB.First(&crawl, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl not found"})
+		return
+	}
+	c.JSON(http.StatusOK, crawl)
+}
+
+/*
+GetCrawlPages handles GET /api/crawls/:id/pages.
+
+Returns the analysis of every page discovered by the crawl, ordered by
+link depth and then discovery time.
+*/
+func GetCrawlPages(c *gin.Context) {
+	var crawl models.Crawl
+	if err := config.DB.First(&crawl, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crawl not found"})
+		return
+	}
+
+	var pages []models.URL
+	if err := config.DB.Where("crawl_id = ?", crawl.ID).Order("crawl_depth asc, created_at asc").Find(&pages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch crawl pages"})
+		return
+	}
+	c.JSON(http.StatusOK, pages)
+}
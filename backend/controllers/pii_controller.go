@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/DMequanint/url-analyzer-pro/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxPIIResults caps the number of URLs returned by a PII search.
+const maxPIIResults = 500
+
+/*
+SearchPII handles GET /api/pii.
+
+Searches the PII exposed by the latest analysis of every URL. The
+optional "q" parameter matches part of the stored value (the full value
+when PII is stored hashed), "type" restricts the search to email, phone
+or a custom pattern name, and "limit" caps the number of URLs returned.
+*/
+func SearchPII(c *gin.Context) {
+	limit := maxPIIResults
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+
+	hits, err := services.SearchPII(c.Query("q"), c.Query("type"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to search PII"})
+		return
+	}
+	c.JSON(http.StatusOK, hits)
+}
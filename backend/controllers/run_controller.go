@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/DMequanint/url-analyzer-pro/repositories"
+	"github.com/DMequanint/url-analyzer-pro/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Page size limits for GET /api/urls/:id/runs.
+const (
+	defaultRunLimit = 50
+	maxRunLimit     = 500
+)
+
+/*
+GetUrlRuns handles GET /api/urls/:id/runs.
+
+Returns the analysis history of a URL, newest first. The optional "limit"
+query parameter caps the number of runs (default 50, max 500). Per-item
+result lists (links, subresources, mixed content, third parties) are
+omitted; fetch a single run for the full result.
+*/
+func GetUrlRuns(c *gin.Context) {
+	var url models.URL
+	if err := config.DB.First(&url, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+
+	limit := defaultRunLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		if n > maxRunLimit {
+			n = maxRunLimit
+		}
+		limit = n
+	}
+
+	runs, err := repositories.GetRunsByURLID(url.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch runs"})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+/*
+GetUrlRunByID handles GET /api/urls/:id/runs/:runId.
+
+Returns a single analysis run with its complete results, or a 404 if the
+run does not exist for this URL.
+*/
+func GetUrlRunByID(c *gin.Context) {
+	run, err := repositories.GetRunByID(c.Param("id"), c.Param("runId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+/*
+GetUrlRunDiff handles GET /api/urls/:id/runs/:runId/diff.
+
+Returns what changed between the run and the previous successful run of
+the same URL. Responds 404 if the run does not exist and 409 if it failed
+or has no earlier successful run to compare with.
+*/
+func GetUrlRunDiff(c *gin.Context) {
+	run, err := repositories.GetRunByID(c.Param("id"), c.Param("runId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+	if run.Status != "done" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Run did not complete successfully"})
+		return
+	}
+
+	diff, err := services.GetRunDiff(&run)
+	if errors.Is(err, services.ErrNoPreviousRun) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to compute diff"})
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
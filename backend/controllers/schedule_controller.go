@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/DMequanint/url-analyzer-pro/pkg/websockethub"
+	"github.com/DMequanint/url-analyzer-pro/services"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+SetUrlSchedule handles PUT /api/urls/:id/schedule.
+
+Sets a recurring analysis schedule for the URL, replacing any existing one.
+
+Example requests:
+
+	{ "interval": 86400 }
+	{ "cron": "0 6 * * 1-5" }
+*/
+func SetUrlSchedule(c *gin.Context) {
+	var url models.URL
+	if err := config.DB.First(&url, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+
+	var req services.ScheduleRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule"})
+		return
+	}
+	if err := services.ApplySchedule(&url, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule: " + err.Error()})
+		return
+	}
+
+	saveSchedule(c, &url)
+}
+
+/*
+DeleteUrlSchedule handles DELETE /api/urls/:id/schedule.
+
+Removes the URL's recurring schedule. The URL keeps its latest results.
+*/
+func DeleteUrlSchedule(c *gin.Context) {
+	var url models.URL
+	if err := config.DB.First(&url, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+
+	services.ClearSchedule(&url)
+	saveSchedule(c, &url)
+}
+
+/*
+PauseUrlSchedule handles POST /api/urls/:id/schedule/pause.
+
+Stops scheduled runs without discarding the schedule.
+*/
+func PauseUrlSchedule(c *gin.Context) {
+	var url models.URL
+	if err := config.DB.First(&url, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+	if !services.HasSchedule(&url) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL has no schedule"})
+		return
+	}
+
+	url.SchedulePaused = true
+	saveSchedule(c, &url)
+}
+
+/*
+ResumeUrlSchedule handles POST /api/urls/:id/schedule/resume.
+
+Reactivates a paused schedule. The next run is computed from now, so runs
+missed while paused are skipped.
+*/
+func ResumeUrlSchedule(c *gin.Context) {
+	var url models.URL
+	if err := config.DB.First(&url, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+	if !services.HasSchedule(&url) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL has no schedule"})
+		return
+	}
+
+	next, err := services.NextRun(&url, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule: " + err.Error()})
+		return
+	}
+	url.SchedulePaused = false
+	url.NextRunAt = &next
+	saveSchedule(c, &url)
+}
+
+// saveSchedule persists the URL's schedule fields and broadcasts the change.
+func saveSchedule(c *gin.Context, url *models.URL) {
+	err := config.DB.Model(url).Select("schedule_interval", "schedule_cron", "schedule_paused", "next_run_at").Updates(url).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save schedule"})
+		return
+	}
+
+	websockethub.BroadcastStatusUpdate(map[string]interface{}{
+		"id":             url.ID,
+		"scheduleCron":   url.ScheduleCron,
+		"schedulePaused": url.SchedulePaused,
+		"nextRunAt":      url.NextRunAt,
+	})
+
+	c.JSON(http.StatusOK, url)
+}
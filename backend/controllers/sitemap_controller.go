@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/DMequanint/url-analyzer-pro/services"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+ImportSitemap handles POST /api/sitemaps.
+
+Accepts a sitemap, sitemap index or site URL and imports it in the
+background: every listed page is queued for analysis and probed for 404s
+and redirects. Returns 202 Accepted with the import record; poll
+GET /api/sitemaps/:id for the report.
+
+Example request:
+
+	{
+		"url": "https://example.com/sitemap.xml.gz"
+	}
+*/
+func ImportSitemap(c *gin.Context) {
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.URL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL"})
+		return
+	}
+
+	sitemap := models.Sitemap{
+		URL:    req.URL,
+		Status: "running",
+	}
+	if err := config.DB.Create(&sitemap).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save sitemap"})
+		return
+	}
+
+	// Work on a copy so the response is not raced by the background import
+	job := sitemap
+	go services.ImportSitemap(&job)
+
+	c.JSON(http.StatusAccepted, sitemap)
+}
+
+/*
+GetAllSitemaps handles GET /api/sitemaps.
+
+Returns all sitemap imports ordered by creation time (descending),
+without their entries.
+*/
+func GetAllSitemaps(c *gin.Context) {
+	var sitemaps []models.Sitemap
+	if err := config.DB.Order("created_at desc").Find(&sitemaps).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch sitemaps"})
+		return
+	}
+	c.JSON(http.StatusOK, sitemaps)
+}
+
+/*
+GetSitemapByID handles GET /api/sitemaps/:id.
+
+Returns the import record with every listed page, including its status
+code and redirect target, or a 404 if not found.
+*/
+func GetSitemapByID(c *gin.Context) {
+	var sitemap models.Sitemap
+	err := config.DB.Preload("Entries").First(&sitemap, "id = ?", c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sitemap not found"})
+		return
+	}
+	c.JSON(http.StatusOK, sitemap)
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/DMequanint/url-analyzer-pro/pkg/blobstore"
+	"github.com/DMequanint/url-analyzer-pro/services"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+GetRunSnapshot handles GET /api/urls/:id/runs/:runId/snapshot.
+
+Returns the metadata of the response captured for the run (final URL,
+status, content type, blob hashes and size), or a 404 if none was kept.
+*/
+func GetRunSnapshot(c *gin.Context) {
+	var snap models.Snapshot
+	err := config.DB.First(&snap, "run_id = ? AND url_id = ?", c.Param("runId"), c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snapshot not found"})
+		return
+	}
+	c.JSON(http.StatusOK, snap)
+}
+
+/*
+DownloadRunSnapshot handles GET /api/urls/:id/runs/:runId/snapshot/:part.
+
+Streams the captured response body ("body") or status line and headers
+("headers") as an attachment. The body is served as
+application/octet-stream so captured HTML is never rendered by the browser.
+*/
+func DownloadRunSnapshot(c *gin.Context) {
+	var snap models.Snapshot
+	err := config.DB.First(&snap, "run_id = ? AND url_id = ?", c.Param("runId"), c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snapshot not found"})
+		return
+	}
+
+	var key, contentType, filename string
+	switch c.Param("part") {
+	case "body":
+		key, contentType, filename = snap.BodyHash, "application/octet-stream", snap.RunID+".body"
+	case "headers":
+		key, contentType, filename = snap.HeadersHash, "text/plain; charset=utf-8", snap.RunID+".headers.txt"
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown snapshot part; use body or headers"})
+		return
+	}
+
+	blob, err := services.OpenSnapshotBlob(c.Request.Context(), key)
+	switch {
+	case errors.Is(err, services.ErrSnapshotsDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, blobstore.ErrNotFound):
+		c.JSON(http.StatusGone, gin.H{"error": "Snapshot content is no longer stored"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to read snapshot"})
+		return
+	}
+	defer blob.Close()
+
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, blob)
+}
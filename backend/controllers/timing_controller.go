@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/DMequanint/url-analyzer-pro/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxTimingSamples caps the number of fetches summarized by GET /api/timings.
+const maxTimingSamples = 10000
+
+/*
+GetTimingPercentiles handles GET /api/timings.
+
+Returns p50/p75/p90/p95/p99/max of each page fetch phase (DNS, connect,
+TLS, TTFB, download, total) across the latest successful analysis of
+every URL. The optional "crawlId" query parameter restricts the set to a
+crawl's pages; "since" (RFC 3339) switches to every successful run
+started since that time, for tracking performance over time. At most
+"limit" of the most recent fetches are summarized (10000 by default and
+at most).
+*/
+func GetTimingPercentiles(c *gin.Context) {
+	limit := maxTimingSamples
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+
+	var timings []models.Timing
+
+	if raw := c.Query("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 timestamp"})
+			return
+		}
+		query := config.DB.Model(&models.AnalysisRun{}).
+			Where("analysis_runs.status = ? AND analysis_runs.started_at >= ?", "done", since)
+		if crawlID := c.Query("crawlId"); crawlID != "" {
+			query = query.Joins("JOIN urls ON urls.id = analysis_runs.url_id").Where("urls.crawl_id = ?", crawlID)
+		}
+		var runs []models.AnalysisRun
+		if err := query.Select("analysis_runs.timing_dns_ms, analysis_runs.timing_connect_ms, analysis_runs.timing_tls_ms, " +
+			"analysis_runs.timing_ttfb_ms, analysis_runs.timing_download_ms, analysis_runs.timing_total_ms").
+			Order("analysis_runs.started_at desc").Limit(limit).Find(&runs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch timings"})
+			return
+		}
+		for _, r := range runs {
+			timings = append(timings, r.PageTiming)
+		}
+	} else {
+		query := config.DB.Model(&models.URL{}).Where("status = ?", "done")
+		if crawlID := c.Query("crawlId"); crawlID != "" {
+			query = query.Where("crawl_id = ?", crawlID)
+		}
+		var urls []models.URL
+		if err := query.Select("timing_dns_ms, timing_connect_ms, timing_tls_ms, " +
+			"timing_ttfb_ms, timing_download_ms, timing_total_ms").
+			Order("last_run_at desc").Limit(limit).Find(&urls).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch timings"})
+			return
+		}
+		for _, u := range urls {
+			timings = append(timings, u.PageTiming)
+		}
+	}
+
+	c.JSON(http.StatusOK, services.SummarizeTimings(timings))
+}
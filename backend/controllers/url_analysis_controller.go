@@ -3,28 +3,83 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/DMequanint/url-analyzer-pro/config"
 	"github.com/DMequanint/url-analyzer-pro/models"
 	"github.com/DMequanint/url-analyzer-pro/pkg/websockethub"
+	"github.com/DMequanint/url-analyzer-pro/repositories"
+	"github.com/DMequanint/url-analyzer-pro/services"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 /*
 GetAllUrls handles GET /api/urls.
 
 Returns a list of all URLs ordered by creation time (descending).
+
+Optional query parameters filter on content metrics: minWords/maxWords
+(visible word count), minReadingEase/maxReadingEase (Flesch reading ease)
+and keyword (one of the page's top keywords).
 */
 func GetAllUrls(c *gin.Context) {
+	query, err := contentFilters(c, config.DB.Model(&models.URL{}))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var urls []models.URL
-	if err := config.DB.Order("created_at desc").Find(&urls).Error; err != nil {
+	if err := query.Order("created_at desc").Find(&urls).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch URLs"})
 		return
 	}
 	c.JSON(http.StatusOK, urls)
 }
 
+// contentFilters applies the content metric filters of GetAllUrls to query.
+func contentFilters(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	ranges := []struct {
+		param, cond string
+		integer     bool
+	}{
+		{"minWords", "word_count >= ?", true},
+		{"maxWords", "word_count <= ?", true},
+		{"minReadingEase", "reading_ease >= ?", false},
+		{"maxReadingEase", "reading_ease <= ?", false},
+	}
+	for _, r := range ranges {
+		raw := c.Query(r.param)
+		if raw == "" {
+			continue
+		}
+		if r.integer {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return nil, errors.New(r.param + " must be an integer")
+			}
+			query = query.Where(r.cond, n)
+		} else {
+			f, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, errors.New(r.param + " must be a number")
+			}
+			query = query.Where(r.cond, f)
+		}
+	}
+	if keyword := strings.ToLower(strings.TrimSpace(c.Query("keyword"))); keyword != "" {
+		// JSON_SEARCH treats % and _ as wildcards
+		keyword = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(keyword)
+		query = query.Where("JSON_SEARCH(top_keywords, 'one', ?, NULL, '$[*].word') IS NOT NULL", keyword)
+	}
+	return query, nil
+}
+
 /*
 GetUrlByID handles GET /api/urls/:id.
 
@@ -39,33 +94,139 @@ func GetUrlByID(c *gin.Context) {
 	c.JSON(http.StatusOK, url)
 }
 
+/*
+GetUrlChildren handles GET /api/urls/:id/children.
+
+Returns the same-origin frames of a URL that were queued for analysis as
+child pages, oldest first.
+*/
+func GetUrlChildren(c *gin.Context) {
+	var parent models.URL
+	if err := config.DB.First(&parent, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+
+	var children []models.URL
+	if err := config.DB.Where("parent_url_id = ?", parent.ID).Order("created_at asc").Find(&children).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch child pages"})
+		return
+	}
+	c.JSON(http.StatusOK, children)
+}
+
 /*
 CreateUrl handles POST /api/urls.
 
 Accepts a JSON payload with a URL string and persists it for analysis.
 Initial status is set to "queued". Broadcasts the URL over WebSocket.
 
+An optional request profile customizes the analyzer's requests. It is
+encrypted before being stored and never returned by the API; without
+PROFILE_ENCRYPTION_KEY, requests carrying one are refused with 503.
+
+Setting "type" to "crawl" starts a multi-page crawl at the URL; the
+optional "crawl" object sets its depth and page limits and include/exclude
+URL patterns. The response then carries the new crawl's ID in "crawlId".
+
+An optional "schedule" ({"interval": seconds} or {"cron": "expr"}) makes
+the URL re-analyzed periodically after its first run.
+
 Example request:
-{
-	"url": "https://example.com"
-}
+
+	{
+		"url": "https://staging.example.com",
+		"profile": {
+			"userAgent": "Mozilla/5.0 (compatible; url-analyzer)",
+			"headers": { "X-Env": "staging" },
+			"cookies": { "session": "abc123" },
+			"auth": { "type": "basic", "username": "qa", "password": "secret" }
+		},
+		"type": "crawl",
+		"crawl": { "maxDepth": 2, "maxPages": 100, "exclude": ["/logout"] },
+		"schedule": { "cron": "0 6 * * *" }
+	}
 */
 func CreateUrl(c *gin.Context) {
 	var req struct {
-		URL string `json:"url"`
+		URL      string                    `json:"url"`
+		Profile  *models.RequestProfile    `json:"profile"`
+		Type     string                    `json:"type"`
+		Crawl    services.CrawlOptions     `json:"crawl"`
+		Schedule *services.ScheduleRequest `json:"schedule"`
 	}
 	if err := c.BindJSON(&req); err != nil || req.URL == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL"})
 		return
 	}
+	if req.Type == "" {
+		req.Type = services.JobTypePage
+	}
+	if req.Type != services.JobTypePage && req.Type != services.JobTypeCrawl {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job type"})
+		return
+	}
 
 	newURL := models.URL{
 		URL:           req.URL,
 		NormalizedURL: req.URL, // You can normalize before saving
 		Status:        "queued",
+		JobType:       req.Type,
+	}
+
+	if req.Schedule != nil {
+		if err := services.ApplySchedule(&newURL, *req.Schedule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule: " + err.Error()})
+			return
+		}
+	}
+
+	var crawl *models.Crawl
+	if req.Type == services.JobTypeCrawl {
+		var err error
+		if crawl, err = services.NewCrawl(req.URL, req.Crawl); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid crawl settings: " + err.Error()})
+			return
+		}
+	}
+
+	if req.Profile != nil {
+		if err := services.ValidateRequestProfile(req.Profile); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request profile: " + err.Error()})
+			return
+		}
+		sealed, err := services.EncryptRequestProfile(req.Profile)
+		if errors.Is(err, services.ErrProfileKeyMissing) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Request profiles are not enabled on this server"})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to encrypt request profile: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store request profile"})
+			return
+		}
+		newURL.RequestProfile = sealed
+		newURL.HasRequestProfile = true
 	}
 
-	if err := config.DB.Create(&newURL).Error; err != nil {
+	// The crawl and its root page are created together so neither is left orphaned
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if crawl != nil {
+			if err := tx.Create(crawl).Error; err != nil {
+				return err
+			}
+			newURL.CrawlID = &crawl.ID
+		}
+		if err := tx.Create(&newURL).Error; err != nil {
+			return err
+		}
+		if crawl != nil {
+			crawl.RootURLID = newURL.ID
+			return tx.Model(crawl).Update("root_url_id", newURL.ID).Error
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save URL"})
 		return
 	}
@@ -76,6 +237,7 @@ func CreateUrl(c *gin.Context) {
 		"url":           newURL.URL,
 		"normalizedURL": newURL.NormalizedURL,
 		"status":        newURL.Status,
+		"crawlId":       newURL.CrawlID,
 	})
 
 	c.JSON(http.StatusCreated, newURL)
@@ -84,8 +246,9 @@ func CreateUrl(c *gin.Context) {
 /*
 AnalyzeUrlByID handles POST /api/urls/:id/analyze.
 
-Resets URL analysis values and marks it as "queued"
-so the background analyzer includes it in the next scan.
+Marks the URL as "queued" so the background analyzer includes it in the
+next scan. The latest results stay in place until the new run completes;
+earlier runs remain available under /api/urls/:id/runs.
 */
 func AnalyzeUrlByID(c *gin.Context) {
 	var url models.URL
@@ -94,24 +257,9 @@ func AnalyzeUrlByID(c *gin.Context) {
 		return
 	}
 
-	// Reset analysis fields before reprocessing
+	// Only the status is written, so a schedule changed meanwhile is kept
 	url.Status = "queued"
-	url.ErrorReason = ""
-	url.ErrorCode = 0
-	url.PageTitle = ""
-	url.HTMLVersion = ""
-	url.InternalLinksCount = 0
-	url.ExternalLinksCount = 0
-	url.InaccessibleLinksCount = 0
-	url.HasLoginForm = false
-	url.H1 = 0
-	url.H2 = 0
-	url.H3 = 0
-	url.H4 = 0
-	url.H5 = 0
-	url.H6 = 0
-
-	if err := config.DB.Save(&url).Error; err != nil {
+	if err := config.DB.Model(&url).Update("status", url.Status).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue analysis"})
 		return
 	}
@@ -137,7 +285,8 @@ func RetryUrlAnalysis(c *gin.Context) {
 /*
 DeleteUrl handles DELETE /api/urls/:id.
 
-Deletes the URL and broadcasts its removal.
+Deletes the URL, its analysis runs and response snapshots, and broadcasts
+its removal.
 Returns 204 No Content on success.
 */
 func DeleteUrl(c *gin.Context) {
@@ -146,6 +295,16 @@ func DeleteUrl(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete URL"})
 		return
 	}
+	if err := services.DeleteSnapshots(id); err != nil {
+		log.Printf("Failed to delete snapshots of %s: %v", id, err)
+	}
+	if err := repositories.DeleteRunsByURLID(id); err != nil {
+		log.Printf("Failed to delete runs of %s: %v", id, err)
+	}
+	// Child pages of its frames remain as standalone URLs
+	if err := config.DB.Model(&models.URL{}).Where("parent_url_id = ?", id).Update("parent_url_id", nil).Error; err != nil {
+		log.Printf("Failed to detach child pages of %s: %v", id, err)
+	}
 
 	websockethub.BroadcastStatusUpdate(map[string]interface{}{
 		"id":     id,
@@ -154,4 +313,3 @@ func DeleteUrl(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
-
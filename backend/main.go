@@ -8,6 +8,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -23,6 +25,7 @@ import (
 	"github.com/DMequanint/url-analyzer-pro/controllers"
 	"github.com/DMequanint/url-analyzer-pro/models"
 	"github.com/DMequanint/url-analyzer-pro/pkg/websockethub"
+	"github.com/DMequanint/url-analyzer-pro/repositories"
 	"github.com/DMequanint/url-analyzer-pro/services"
 )
 
@@ -43,6 +46,29 @@ func handleWS(c *gin.Context) {
 	websockethub.Register(conn)
 }
 
+/*
+finishRun stores the URL's final status and results on its analysis run,
+if one was recorded, keeps the captured response as the run's snapshot,
+and diffs a successful run against the previous one.
+*/
+func finishRun(run *models.AnalysisRun, u *models.URL) {
+	if run == nil {
+		return
+	}
+	if err := repositories.FinishRun(run, u); err != nil {
+		log.Printf("Failed to save run %s: %v", run.ID, err)
+		return
+	}
+	if err := services.StoreSnapshot(run); err != nil {
+		log.Printf("Failed to store snapshot of run %s: %v", run.ID, err)
+	}
+	if run.Status == "done" {
+		if _, err := services.RecordRunDiff(run); err != nil && !errors.Is(err, services.ErrNoPreviousRun) {
+			log.Printf("Failed to diff run %s: %v", run.ID, err)
+		}
+	}
+}
+
 func main() {
 	// Load environment variables from .env if it exists
 	_ = godotenv.Load()
@@ -71,9 +97,33 @@ func main() {
 		workerCount = 1
 	}
 
+	// Load analyzer tunables (outbound request limits, subresource fetching)
+	config.LoadAnalyzerConfig()
+	if proxy := config.Analyzer.ProxyURL; proxy != "" {
+		if err := services.ValidateProxyURL(proxy); err != nil {
+			log.Fatalf("Invalid ANALYZE_PROXY_URL: %v", err)
+		}
+	}
+	if err := services.InitSnapshotStore(); err != nil {
+		log.Fatalf("Failed to open snapshot store: %v", err)
+	}
+	if path := config.Analyzer.TrackerListPath; path != "" {
+		if err := services.LoadTrackerList(path); err != nil {
+			log.Printf("Failed to load tracker list %s: %v", path, err)
+		}
+	}
+	if config.Analyzer.PIIMode == config.PIIHash && len(config.Analyzer.PIIHashKey) == 0 {
+		log.Fatalf("PII_MODE=hash requires PII_HASH_KEY")
+	}
+	if path := config.Analyzer.PIIPatternsPath; path != "" {
+		if err := services.LoadPIIPatterns(path); err != nil {
+			log.Printf("Failed to load PII patterns %s: %v", path, err)
+		}
+	}
+
 	// Connect to the database and run auto-migrations
 	config.ConnectDatabase()
-	if err := config.DB.AutoMigrate(&models.URL{}); err != nil {
+	if err := config.DB.AutoMigrate(&models.URL{}, &models.AnalysisRun{}, &models.RunDiff{}, &models.Snapshot{}, &models.Crawl{}, &models.Sitemap{}, &models.SitemapEntry{}); err != nil {
 		log.Fatalf("Failed to auto-migrate schema: %v", err)
 	}
 
@@ -96,7 +146,18 @@ func main() {
 				url := &queued[i]
 
 				url.Status = "running"
-				_ = config.DB.Save(url)
+
+				// Record the run; its results are filled in when the analysis completes
+				run, err := repositories.StartRun(url)
+				if err != nil {
+					log.Printf("Failed to record run for %s: %v", url.ID, err)
+					run = nil
+				} else {
+					url.LatestRunID = run.ID
+				}
+				if err := repositories.MarkURLRunning(url); err != nil {
+					log.Printf("Failed to mark %s as running: %v", url.ID, err)
+				}
 
 				websockethub.BroadcastStatusUpdate(map[string]interface{}{
 					"id":     url.ID,
@@ -106,50 +167,110 @@ func main() {
 				semaphore <- struct{}{} // Blocks if worker limit is reached
 
 				// Run analysis in a separate goroutine
-				go func(u *models.URL) {
+				go func(u *models.URL, run *models.AnalysisRun) {
 					defer func() { <-semaphore }()
 
-					result := make(chan bool, 1)
+					// The analysis fills in a copy of the URL and its requests are
+					// cancelled on timeout, so an abandoned analysis never touches
+					// the record saved below
+					ctx, cancel := context.WithTimeout(context.Background(), timeout)
+					defer cancel()
+					work := *u
+					result := make(chan error, 1)
 
 					go func() {
-						err := services.AnalyzeURL(u)
-						if err != nil {
-							u.Status = "error"
-							u.ErrorReason = err.Error()
-						} else {
-							u.Status = "done"
-						}
-						config.DB.Save(u)
-						result <- true
+						result <- services.AnalyzeURL(ctx, &work)
 					}()
 
 					select {
-					case <-result:
+					case err := <-result:
 						// Completed (success or error)
-					case <-time.After(timeout):
+						*u = work
+						switch {
+						case err != nil && ctx.Err() != nil:
+							u.Status = "error"
+							u.ErrorReason = "Timed out"
+						case err != nil:
+							u.Status = "error"
+							u.ErrorReason = err.Error()
+						default:
+							u.Status = "done"
+						}
+					case <-ctx.Done():
+						u.ResetAnalysis()
 						u.Status = "error"
 						u.ErrorReason = "Timed out"
-						config.DB.Save(u)
 					}
+					finished := time.Now()
+					u.LastRunAt = &finished
+					// A URL deleted during the run is not recreated, nor is its history
+					found, err := repositories.SaveURLAnalysis(u)
+					if err != nil {
+						log.Printf("Failed to save analysis of %s: %v", u.ID, err)
+					}
+					if !found && err == nil {
+						return
+					}
+					finishRun(run, u)
 
 					// Notify clients via WebSocket with full analysis results
 					websockethub.BroadcastStatusUpdate(map[string]interface{}{
-						"id":                u.ID,
-						"status":            u.Status,
-						"pageTitle":         u.PageTitle,
-						"htmlVersion":       u.HTMLVersion,
-						"internalLinks":     u.InternalLinksCount,
-						"externalLinks":     u.ExternalLinksCount,
-						"inaccessibleLinks": u.InaccessibleLinksCount,
-						"hasLoginForm":      u.HasLoginForm,
-						"errorCode":         u.ErrorCode,
-						"errorReason":       u.ErrorReason,
+						"id":                  u.ID,
+						"status":              u.Status,
+						"pageTitle":           u.PageTitle,
+						"documentType":        u.DocumentType,
+						"htmlVersion":         u.HTMLVersion,
+						"internalLinks":       u.InternalLinksCount,
+						"externalLinks":       u.ExternalLinksCount,
+						"inaccessibleLinks":   u.InaccessibleLinksCount,
+						"hasLoginForm":        u.HasLoginForm,
+						"robotsVerdict":       u.RobotsVerdict,
+						"robotsError":         u.RobotsError,
+						"mixedContentActive":  u.MixedContentActive,
+						"mixedContentPassive": u.MixedContentPassive,
+						"pageWeightBytes":     u.PageWeightBytes,
+						"thirdPartyCount":     u.ThirdPartyCount,
+						"trackerCount":        u.TrackerCount,
+						"errorCode":           u.ErrorCode,
+						"errorReason":         u.ErrorReason,
+						"latestRunId":         u.LatestRunID,
+						"contentHash":         u.ContentHash,
+						"timing":              u.PageTiming,
+						"lastRunAt":           u.LastRunAt,
+						"nextRunAt":           u.NextRunAt,
 					})
-				}(url)
+
+					// Crawl pages queue their internal links and update the crawl totals
+					if u.CrawlID != nil {
+						if u.Status == "done" {
+							if err := services.ExpandCrawl(u); err != nil {
+								log.Printf("Failed to expand crawl %s: %v", *u.CrawlID, err)
+							}
+						}
+						if crawl, err := services.RefreshCrawlStats(*u.CrawlID); err == nil {
+							websockethub.BroadcastStatusUpdate(map[string]interface{}{
+								"crawlId":    crawl.ID,
+								"status":     crawl.Status,
+								"pagesTotal": crawl.PagesTotal,
+								"pagesDone":  crawl.PagesDone,
+							})
+						}
+					}
+
+					// Same-origin frames are analyzed as child pages when enabled
+					if u.Status == "done" {
+						if err := services.QueueChildFrames(u); err != nil {
+							log.Printf("Failed to queue child frames of %s: %v", u.ID, err)
+						}
+					}
+				}(url, run)
 			}
 		}
 	}()
 
+	// Requeue URLs whose recurring schedule is due
+	go services.RunScheduler(time.Duration(intervalSec) * time.Second)
+
 	// Set up the Gin router with minimal logging and recovery middleware
 	r := gin.New()
 	r.Use(gin.Recovery())
@@ -170,6 +291,25 @@ func main() {
 	r.POST("/api/urls/:id/analyze", controllers.AnalyzeUrlByID)
 	r.POST("/api/urls/:id/retry", controllers.RetryUrlAnalysis)
 	r.DELETE("/api/urls/:id", controllers.DeleteUrl)
+	r.GET("/api/urls/:id/children", controllers.GetUrlChildren)
+	r.GET("/api/urls/:id/runs", controllers.GetUrlRuns)
+	r.GET("/api/urls/:id/runs/:runId", controllers.GetUrlRunByID)
+	r.GET("/api/urls/:id/runs/:runId/diff", controllers.GetUrlRunDiff)
+	r.GET("/api/urls/:id/runs/:runId/snapshot", controllers.GetRunSnapshot)
+	r.GET("/api/urls/:id/runs/:runId/snapshot/:part", controllers.DownloadRunSnapshot)
+	r.PUT("/api/urls/:id/schedule", controllers.SetUrlSchedule)
+	r.DELETE("/api/urls/:id/schedule", controllers.DeleteUrlSchedule)
+	r.POST("/api/urls/:id/schedule/pause", controllers.PauseUrlSchedule)
+	r.POST("/api/urls/:id/schedule/resume", controllers.ResumeUrlSchedule)
+	r.GET("/api/timings", controllers.GetTimingPercentiles)
+	r.GET("/api/pii", controllers.SearchPII)
+	r.GET("/api/cookies", controllers.GetCookieReport)
+	r.GET("/api/crawls", controllers.GetAllCrawls)
+	r.GET("/api/crawls/:id", controllers.GetCrawlByID)
+	r.GET("/api/crawls/:id/pages", controllers.GetCrawlPages)
+	r.GET("/api/sitemaps", controllers.GetAllSitemaps)
+	r.GET("/api/sitemaps/:id", controllers.GetSitemapByID)
+	r.POST("/api/sitemaps", controllers.ImportSitemap)
 
 	// Define the WebSocket route
 	r.GET("/ws", handleWS)
@@ -180,4 +320,3 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
-
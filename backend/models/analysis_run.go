@@ -0,0 +1,137 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+/*
+AnalysisResult holds every metric produced by a single page analysis:
+page structure (title, headings, links), robots.txt verdict, login form
+detection, mixed content, subresources and page weight, third-party
+hosts, the metadata and content hash used for change detection, content
+metrics of the visible text, language and hreflang checks, structured
+data, the timing breakdown of the page fetch, the caching and
+compression audit, the image audit, the discovered feeds, manifests
+and icons, the PII exposed by the page, the cookies it sets, the
+evaluation of its Content Security Policy, and its embedded frames
+and plugin content. PDF and plain-text
+documents fill in the subset that applies to them.
+
+It is embedded both in URL, as the latest snapshot, and in AnalysisRun,
+as the permanent record of each run. Embedded fields keep their own
+column names and are flattened in JSON.
+*/
+type AnalysisResult struct {
+	PageTitle              string                                 `json:"pageTitle"`                                      // Extracted <title> from the page
+	HTMLVersion            string                                 `json:"htmlVersion"`                                    // Detected HTML doctype/version
+	DocumentType           string                                 `gorm:"size:16;index" json:"documentType"`              // html, pdf or text
+	PageCount              int                                    `json:"pageCount"`                                      // Pages of a PDF document
+	LineCount              int                                    `json:"lineCount"`                                      // Lines of a text document
+	InternalLinksCount     int                                    `json:"internalLinks"`                                  // Number of internal links on page
+	ExternalLinksCount     int                                    `json:"externalLinks"`                                  // Number of external links
+	InaccessibleLinksCount int                                    `json:"inaccessibleLinks"`                              // Links that failed to load
+	HasLoginForm           bool                                   `json:"hasLoginForm"`                                   // Presence of a login form
+	H1                     int                                    `json:"h1"`                                             // Count of <h1> tags
+	H2                     int                                    `json:"h2"`                                             // Count of <h2> tags
+	H3                     int                                    `json:"h3"`                                             // Count of <h3> tags
+	H4                     int                                    `json:"h4"`                                             // Count of <h4> tags
+	H5                     int                                    `json:"h5"`                                             // Count of <h5> tags
+	H6                     int                                    `json:"h6"`                                             // Count of <h6> tags
+	Links                  datatypes.JSONSlice[Link]              `json:"links"`                                          // Unique hyperlinks with check results
+	RobotsVerdict          string                                 `json:"robotsVerdict"`                                  // allowed, disallowed or not_checked
+	RobotsError            string                                 `json:"robotsError,omitempty"`                          // Why robots.txt could not be checked, when analyzed anyway under the report policy
+	RobotsBlockedLinks     int                                    `json:"robotsBlockedLinks"`                             // Links disallowed by robots.txt
+	MixedContentActive     int                                    `json:"mixedContentActive"`                             // Blockable HTTP subresources on an HTTPS page
+	MixedContentPassive    int                                    `json:"mixedContentPassive"`                            // Optionally-blockable HTTP subresources (images, media)
+	MixedContent           datatypes.JSONSlice[Resource]          `json:"mixedContent"`                                   // Insecure subresource references
+	HTMLBytes              int64                                  `json:"htmlBytes"`                                      // Size of the HTML document in bytes
+	PageWeightBytes        int64                                  `json:"pageWeightBytes"`                                // HTML plus all fetched subresource sizes
+	SubresourceCount       int                                    `json:"subresourceCount"`                               // Number of unique subresources referenced
+	Subresources           datatypes.JSONSlice[Resource]          `json:"subresources"`                                   // Scripts, styles, images, fonts... with fetch metadata
+	ThirdPartyCount        int                                    `json:"thirdPartyCount"`                                // Number of distinct third-party hosts
+	TrackerCount           int                                    `json:"trackerCount"`                                   // Third-party hosts found in the tracker list
+	ThirdParties           datatypes.JSONSlice[ThirdPartyHost]    `json:"thirdParties"`                                   // Per-host third-party inventory
+	Headings               datatypes.JSONSlice[Heading]           `json:"headings"`                                       // h1-h6 texts in document order
+	Meta                   datatypes.JSONSlice[MetaTag]           `json:"meta"`                                           // Named <meta> tags
+	CanonicalURL           string                                 `json:"canonicalUrl"`                                   // <link rel="canonical"> target
+	ContentHash            string                                 `json:"contentHash"`                                    // SHA-256 of the HTML document
+	WordCount              int                                    `json:"wordCount"`                                      // Words in the visible body text
+	SentenceCount          int                                    `json:"sentenceCount"`                                  // Sentences in the visible body text
+	ReadingEase            float64                                `gorm:"index" json:"readingEase"`                       // Flesch reading ease (higher is easier)
+	ReadingGrade           float64                                `json:"readingGrade"`                                   // Flesch-Kincaid grade level
+	TextToHTMLRatio        float64                                `json:"textToHtmlRatio"`                                // Visible text bytes as a percentage of HTML bytes
+	TopKeywords            datatypes.JSONSlice[KeywordCount]      `json:"topKeywords"`                                    // Most frequent non-stopword words
+	HTMLLang               string                                 `json:"htmlLang"`                                       // lang attribute of <html>
+	ContentLanguage        string                                 `json:"contentLanguage"`                                // Content-Language response header
+	DetectedLanguage       string                                 `json:"detectedLanguage"`                               // ISO 639-1 code detected from the visible text
+	LanguageConfidence     float64                                `json:"languageConfidence"`                             // Detection confidence between 0 and 1
+	LanguageMismatch       bool                                   `gorm:"index" json:"languageMismatch"`                  // Declared and detected languages differ
+	HreflangSelfReference  bool                                   `json:"hreflangSelfReference"`                          // hreflang alternates include the page itself
+	HreflangIssues         int                                    `json:"hreflangIssues"`                                 // Alternates with problems, plus a missing self reference
+	HreflangAlternates     datatypes.JSONSlice[HreflangAlternate] `json:"hreflangAlternates"`                             // hreflang alternates with check results
+	StructuredDataCount    int                                    `json:"structuredDataCount"`                            // Top-level schema.org entities found
+	StructuredDataInvalid  int                                    `json:"structuredDataInvalid"`                          // Entities failing to parse or missing required properties
+	StructuredData         datatypes.JSONSlice[StructuredItem]    `json:"structuredData"`                                 // JSON-LD, Microdata and RDFa entities
+	PageTiming             Timing                                 `gorm:"embedded;embeddedPrefix:timing_" json:"timing"`  // Timing breakdown of the page fetch
+	PageCache              CacheHeaders                           `gorm:"embedded;embeddedPrefix:page_" json:"pageCache"` // Caching/compression headers of the page
+	CacheIssueCount        int                                    `json:"cacheIssueCount"`                                // Caching/compression findings across the page and subresources
+	ImageCount             int                                    `json:"imageCount"`                                     // Images found on the page
+	ImagesMissingAlt       int                                    `json:"imagesMissingAlt"`                               // <img> elements without an alt attribute
+	ImageIssueCount        int                                    `json:"imageIssueCount"`                                // Image audit findings
+	ImageBytes             int64                                  `json:"imageBytes"`                                     // Bytes of the images with a known size
+	Images                 datatypes.JSONSlice[Image]             `json:"images"`                                         // Image inventory with audit findings
+	DiscoveryIssueCount    int                                    `json:"discoveryIssueCount"`                            // Discovered assets that failed validation
+	Discovered             datatypes.JSONSlice[DiscoveredAsset]   `json:"discovered"`                                     // Feeds, manifests, icons and OpenSearch descriptors
+	PIICount               int                                    `json:"piiCount"`                                       // Distinct PII values exposed by the page
+	PII                    datatypes.JSONSlice[PIIMatch]          `json:"pii"`                                            // Detected emails, phone numbers and custom patterns
+	CookieCount            int                                    `json:"cookieCount"`                                    // Cookies set during the analysis
+	ThirdPartyCookieCount  int                                    `json:"thirdPartyCookieCount"`                          // Cookies set for third-party domains
+	InsecureCookieCount    int                                    `json:"insecureCookieCount"`                            // Cookies with at least one issue
+	Cookies                datatypes.JSONSlice[Cookie]            `json:"cookies"`                                        // Cookie inventory with attribute findings
+	CSPPolicies            datatypes.JSONSlice[CSPPolicy]         `json:"cspPolicies"`                                    // Content Security Policies from headers and <meta>
+	CSPFindingCount        int                                    `json:"cspFindingCount"`                                // Content Security Policy findings
+	CSPHighCount           int                                    `json:"cspHighCount"`                                   // High-severity Content Security Policy findings
+	CSPFindings            datatypes.JSONSlice[CSPFinding]        `json:"cspFindings"`                                    // Policy weaknesses and mismatches with the page
+	EmbedCount             int                                    `json:"embedCount"`                                     // Iframes, frames, embeds and objects on the page
+	EmbedIssueCount        int                                    `json:"embedIssueCount"`                                // Embed audit findings
+	Embeds                 datatypes.JSONSlice[Embed]             `json:"embeds"`                                         // Embedded content with audit findings
+	Capture                *ResponseCapture                       `gorm:"-" json:"-"`                                     // Raw response awaiting snapshot storage
+}
+
+/*
+AnalysisRun is the permanent record of one analysis of a URL: its
+outcome, timing and the full set of results it produced.
+
+A run is created when a worker picks up a queued URL and completed when
+the analysis finishes, fails or times out.
+*/
+type AnalysisRun struct {
+	ID          string     `gorm:"primaryKey" json:"id"`   // Unique UUID primary key
+	URLID       string     `gorm:"index" json:"urlId"`     // URL the run belongs to
+	Status      string     `gorm:"index" json:"status"`    // running, done, error
+	ErrorReason string     `json:"errorReason"`            // If failed, reason string
+	ErrorCode   int        `json:"errorCode"`              // HTTP or custom error code (e.g. 408)
+	StartedAt   time.Time  `gorm:"index" json:"startedAt"` // When the worker started the run
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`   // When the run completed
+	DurationMs  int64      `json:"durationMs"`             // Wall-clock duration of the run
+	CreatedAt   time.Time  `json:"created_at"`             // Timestamp when the run was recorded
+
+	// Metrics produced by the run
+	AnalysisResult `gorm:"embedded"`
+}
+
+/*
+BeforeCreate is a GORM lifecycle hook that runs before insertion.
+
+Ensures every AnalysisRun record gets a UUID assigned as ID.
+*/
+func (r *AnalysisRun) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.NewString()
+	}
+	return
+}
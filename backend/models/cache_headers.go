@@ -0,0 +1,21 @@
+package models
+
+import "gorm.io/datatypes"
+
+/*
+CacheHeaders holds the caching and compression related headers of a
+response, together with the audit findings derived from them.
+
+CacheIssues contains any of "uncompressed" (a text response served without
+Content-Encoding), "no-validator" (neither ETag nor Last-Modified) and
+"no-store-static" (a static asset marked Cache-Control: no-store).
+*/
+type CacheHeaders struct {
+	CacheControl    string                      `json:"cacheControl,omitempty"`    // Cache-Control response header
+	Expires         string                      `json:"expires,omitempty"`         // Expires response header
+	ETag            string                      `json:"etag,omitempty"`            // ETag response header
+	LastModified    string                      `json:"lastModified,omitempty"`    // Last-Modified response header
+	Vary            string                      `json:"vary,omitempty"`            // Vary response header
+	ContentEncoding string                      `json:"contentEncoding,omitempty"` // Content-Encoding of the response as sent
+	CacheIssues     datatypes.JSONSlice[string] `json:"cacheIssues,omitempty"`     // Audit findings
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+/*
+Cookie is a cookie set through a Set-Cookie header while analyzing a page:
+on the page response, any redirect leading to it, or a fetched subresource.
+
+Cookies are third-party when their domain's registrable domain (eTLD+1)
+differs from the page's. Issues lists the insecure or non-compliant
+attributes found, see the CookieIssue constants of the services package.
+*/
+type Cookie struct {
+	Name       string                      `json:"name"`               // Cookie name
+	Domain     string                      `json:"domain"`             // Domain attribute, or the setting host for host-only cookies
+	HostOnly   bool                        `json:"hostOnly"`           // Whether no Domain attribute was given
+	Path       string                      `json:"path"`               // Path attribute
+	Expires    *time.Time                  `json:"expires,omitempty"`  // Expiry from Expires or Max-Age; nil for session cookies
+	Secure     bool                        `json:"secure"`             // Secure attribute
+	HTTPOnly   bool                        `json:"httpOnly"`           // HttpOnly attribute
+	SameSite   string                      `json:"sameSite,omitempty"` // Strict, Lax, None or empty when not set
+	ThirdParty bool                        `json:"thirdParty"`         // Set for a domain other than the page's
+	Source     string                      `json:"source"`             // page (including redirects) or subresource
+	SetBy      string                      `json:"setBy"`              // URL whose response set the cookie
+	Issues     datatypes.JSONSlice[string] `json:"issues,omitempty"`   // Insecure attribute findings
+}
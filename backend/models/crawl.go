@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+/*
+Crawl groups the page analyses of a multi-page site crawl.
+
+A crawl starts at a root URL and follows internal links up to MaxDepth
+link hops and MaxPages pages. Every discovered page is stored as a regular
+URL record carrying the crawl's ID; the counters below are aggregated from
+those pages whenever one of them finishes.
+*/
+type Crawl struct {
+	ID        string                      `gorm:"primaryKey" json:"id"`   // Unique UUID primary key
+	RootURLID string                      `gorm:"index" json:"rootUrlId"` // URL record of the start page
+	StartURL  string                      `json:"startUrl"`               // URL the crawl started from
+	Status    string                      `gorm:"index" json:"status"`    // running, done
+	MaxDepth  int                         `json:"maxDepth"`               // Maximum link hops from the start page
+	MaxPages  int                         `json:"maxPages"`               // Maximum pages analyzed
+	Include   datatypes.JSONSlice[string] `json:"include"`                // Regexps a page URL must match (any)
+	Exclude   datatypes.JSONSlice[string] `json:"exclude"`                // Regexps that exclude a page URL
+
+	PagesTotal             int   `json:"pagesTotal"`         // Pages discovered so far
+	PagesQueued            int   `json:"pagesQueued"`        // Pages waiting for or under analysis
+	PagesDone              int   `json:"pagesDone"`          // Pages analyzed successfully
+	PagesFailed            int   `json:"pagesFailed"`        // Pages whose analysis failed
+	InternalLinksCount     int   `json:"internalLinks"`      // Sum of internal links across pages
+	ExternalLinksCount     int   `json:"externalLinks"`      // Sum of external links across pages
+	InaccessibleLinksCount int   `json:"inaccessibleLinks"`  // Sum of inaccessible links across pages
+	PagesWithLoginForm     int   `json:"pagesWithLoginForm"` // Pages containing a login form
+	PageWeightBytes        int64 `json:"pageWeightBytes"`    // Sum of page weights across pages
+	MixedContentPages      int   `json:"mixedContentPages"`  // Pages with any mixed content
+
+	CreatedAt  time.Time  `json:"created_at"`           // Timestamp when the crawl was submitted
+	FinishedAt *time.Time `json:"finishedAt,omitempty"` // Timestamp when the last page finished
+}
+
+/*
+BeforeCreate is a GORM lifecycle hook that runs before insertion.
+
+Ensures every Crawl record gets a UUID assigned as ID.
+*/
+func (c *Crawl) BeforeCreate(tx *gorm.DB) (err error) {
+	if c.ID == "" {
+		c.ID = uuid.NewString()
+	}
+	return
+}
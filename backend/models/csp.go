@@ -0,0 +1,30 @@
+package models
+
+import "gorm.io/datatypes"
+
+// CSPDirective is a single directive of a Content Security Policy with its source list.
+type CSPDirective struct {
+	Name    string   `json:"name"`              // Directive name, lowercased (script-src, object-src...)
+	Sources []string `json:"sources,omitempty"` // Source expressions as written
+}
+
+/*
+CSPPolicy is a Content Security Policy delivered with an analyzed page,
+parsed into its directives.
+*/
+type CSPPolicy struct {
+	Delivery   string                            `json:"delivery"`   // header, report-only or meta
+	Raw        string                            `json:"raw"`        // Policy as delivered
+	Directives datatypes.JSONSlice[CSPDirective] `json:"directives"` // Parsed directives in declaration order
+}
+
+/*
+CSPFinding is a weakness of the page's Content Security Policy, or a
+mismatch between the policy and what the page actually loads.
+*/
+type CSPFinding struct {
+	Severity  string `json:"severity"`            // high, medium, low or info
+	Code      string `json:"code"`                // Machine-readable finding, e.g. unsafe-inline
+	Directive string `json:"directive,omitempty"` // Directive the finding applies to
+	Message   string `json:"message"`             // Human-readable explanation
+}
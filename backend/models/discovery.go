@@ -0,0 +1,28 @@
+package models
+
+import "gorm.io/datatypes"
+
+/*
+DiscoveredAsset is a feed, web app manifest, icon or OpenSearch
+descriptor declared in a <link> tag of an analyzed page. When no icon is
+declared, the implicit /favicon.ico is recorded instead.
+
+The fetch and validation fields are only filled in when discovery checks
+are enabled; Valid is nil for assets that were not checked.
+*/
+type DiscoveredAsset struct {
+	Kind        string                      `json:"kind"`                  // feed, manifest, icon, apple-touch-icon or opensearch
+	URL         string                      `json:"url"`                   // Absolute URL of the asset
+	Type        string                      `json:"type,omitempty"`        // Declared type attribute
+	Title       string                      `json:"title,omitempty"`       // Declared title attribute
+	Sizes       string                      `json:"sizes,omitempty"`       // Declared sizes attribute (icons)
+	Implicit    bool                        `json:"implicit,omitempty"`    // /favicon.ico fallback, not declared on the page
+	Checked     bool                        `json:"checked"`               // Whether the asset was fetched
+	StatusCode  int                         `json:"statusCode,omitempty"`  // HTTP status of the fetch
+	ContentType string                      `json:"contentType,omitempty"` // Content-Type response header
+	Format      string                      `json:"format,omitempty"`      // rss, atom, rdf or json for feeds
+	Name        string                      `json:"name,omitempty"`        // Feed title, manifest name or OpenSearch ShortName
+	Valid       *bool                       `json:"valid,omitempty"`       // Whether the asset passed validation
+	Missing     datatypes.JSONSlice[string] `json:"missing,omitempty"`     // Required fields the asset lacks
+	Error       string                      `json:"error,omitempty"`       // Network, parse or validation error
+}
@@ -0,0 +1,27 @@
+package models
+
+import "gorm.io/datatypes"
+
+/*
+Embed is an <iframe>, <frame>, <embed> or <object> found on a page.
+
+Category classifies well-known embed providers (video players, maps,
+forms, ads, social widgets) from the source host. Issues lists the
+findings of the audit, see the EmbedIssue constants of the services
+package.
+*/
+type Embed struct {
+	Tag        string                      `json:"tag"`                // iframe, frame, embed or object
+	URL        string                      `json:"url"`                // Absolute src (data for <object>), empty for srcdoc frames
+	Host       string                      `json:"host,omitempty"`     // Host of the source URL
+	SameOrigin bool                        `json:"sameOrigin"`         // Same scheme, host and port as the page
+	ThirdParty bool                        `json:"thirdParty"`         // Registrable domain differs from the page's
+	Category   string                      `json:"category,omitempty"` // video, map, form, ad or social
+	Title      string                      `json:"title,omitempty"`    // title attribute
+	Sandboxed  bool                        `json:"sandboxed"`          // Whether a sandbox attribute is present
+	Sandbox    datatypes.JSONSlice[string] `json:"sandbox,omitempty"`  // Restrictions lifted by the sandbox attribute
+	Allow      string                      `json:"allow,omitempty"`    // Permissions policy of the allow attribute
+	Loading    string                      `json:"loading,omitempty"`  // loading attribute (lazy, eager)
+	Type       string                      `json:"type,omitempty"`     // type attribute of <embed> and <object>
+	Issues     datatypes.JSONSlice[string] `json:"issues,omitempty"`   // Audit findings
+}
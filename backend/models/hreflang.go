@@ -0,0 +1,31 @@
+package models
+
+/*
+HreflangAlternate is a <link rel="alternate" hreflang> entry of an
+analyzed page.
+
+ValidCode reports whether Hreflang is "x-default" or a well-formed BCP 47
+language tag. When alternates are checked, the target is fetched and
+Reciprocal records whether it links back to the analyzed page.
+*/
+type HreflangAlternate struct {
+	Hreflang      string `json:"hreflang"`             // Language code as published, e.g. en-GB or x-default
+	URL           string `json:"url"`                  // Absolute alternate URL
+	ValidCode     bool   `json:"validCode"`            // x-default or a valid BCP 47 tag
+	SelfReference bool   `json:"selfReference"`        // Points at the analyzed page itself
+	Checked       bool   `json:"checked"`              // Whether the target was fetched
+	StatusCode    int    `json:"statusCode,omitempty"` // HTTP status of the target
+	Error         string `json:"error,omitempty"`      // Network error while fetching the target
+	Reciprocal    *bool  `json:"reciprocal,omitempty"` // Target lists the page as an alternate
+}
+
+// HasIssue reports whether the alternate has an invalid code, an unreachable target or no return link.
+func (a HreflangAlternate) HasIssue() bool {
+	if !a.ValidCode {
+		return true
+	}
+	if a.Checked && (a.Error != "" || a.StatusCode >= 400) {
+		return true
+	}
+	return a.Reciprocal != nil && !*a.Reciprocal
+}
@@ -0,0 +1,27 @@
+package models
+
+import "gorm.io/datatypes"
+
+/*
+Image is a single image displayed by an analyzed page: an <img> (possibly
+inside a <picture>) or a background image declared in inline CSS.
+
+Declared dimensions and attributes are recorded as published; Size and,
+where possible, Format come from the subresource fetch when enabled.
+Issues contains any of "missing-alt", "oversized" and "legacy-format".
+*/
+type Image struct {
+	URL          string                      `json:"url"`                    // Absolute image URL (src, or first srcset candidate)
+	Source       string                      `json:"source"`                 // img, picture or css
+	Alt          string                      `json:"alt,omitempty"`          // alt text
+	HasAlt       bool                        `json:"hasAlt"`                 // Whether the alt attribute is present (empty marks decorative images)
+	Width        int                         `json:"width,omitempty"`        // Declared width attribute
+	Height       int                         `json:"height,omitempty"`       // Declared height attribute
+	Srcset       string                      `json:"srcset,omitempty"`       // srcset attribute
+	Sizes        string                      `json:"sizes,omitempty"`        // sizes attribute
+	Loading      string                      `json:"loading,omitempty"`      // loading attribute (lazy, eager)
+	Format       string                      `json:"format,omitempty"`       // jpeg, png, gif, webp, avif, svg...
+	Alternatives datatypes.JSONSlice[string] `json:"alternatives,omitempty"` // Formats offered by sibling <picture> sources
+	Size         int64                       `json:"size"`                   // Bytes transferred, -1 if unknown
+	Issues       datatypes.JSONSlice[string] `json:"issues,omitempty"`       // Audit findings
+}
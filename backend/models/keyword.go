@@ -0,0 +1,7 @@
+package models
+
+// KeywordCount is a frequent word of a page's visible text.
+type KeywordCount struct {
+	Word  string `json:"word"`  // Lowercased word
+	Count int    `json:"count"` // Occurrences in the visible text
+}
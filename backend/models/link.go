@@ -0,0 +1,26 @@
+package models
+
+/*
+Link is a single unique hyperlink found on an analyzed page.
+
+Links are internal when they point at the same host as the page. When link
+checking is enabled, Checked is set and the probe result is recorded in
+StatusCode or Error; Robots holds the robots.txt verdict for the link.
+Probes the host's politeness limits left no time for are marked
+Throttled and stay unchecked, so they are not counted as inaccessible.
+*/
+type Link struct {
+	URL        string  `json:"url"`                  // Absolute link target
+	Internal   bool    `json:"internal"`             // Same host as the analyzed page
+	Checked    bool    `json:"checked"`              // Whether the link was probed
+	StatusCode int     `json:"statusCode,omitempty"` // HTTP status returned by the probe
+	Error      string  `json:"error,omitempty"`      // Network error, if the probe failed
+	Robots     string  `json:"robots,omitempty"`     // allowed, disallowed or not_checked
+	Throttled  bool    `json:"throttled,omitempty"`  // Probe skipped by the per-host politeness limits
+	Timing     *Timing `json:"timing,omitempty"`     // Probe timing, when link timings are enabled
+}
+
+// Inaccessible reports whether a checked link failed or returned an error status.
+func (l Link) Inaccessible() bool {
+	return l.Checked && (l.Error != "" || l.StatusCode >= 400)
+}
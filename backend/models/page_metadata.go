@@ -0,0 +1,16 @@
+package models
+
+// Heading is a single h1-h6 element of an analyzed page, in document order.
+type Heading struct {
+	Level int    `json:"level"` // 1 for <h1> through 6 for <h6>
+	Text  string `json:"text"`  // Visible text with whitespace collapsed
+}
+
+/*
+MetaTag is a <meta> element of an analyzed page carrying a name (or
+Open Graph style property) and content, e.g. description or og:title.
+*/
+type MetaTag struct {
+	Name    string `json:"name"`    // name, property or http-equiv value, lowercased
+	Content string `json:"content"` // content attribute as published
+}
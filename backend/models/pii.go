@@ -0,0 +1,18 @@
+package models
+
+import "gorm.io/datatypes"
+
+/*
+PIIMatch is a distinct piece of personal data exposed by an analyzed page,
+such as an email address or phone number found in the visible text or in
+a mailto:/tel: link.
+
+Value holds the normalized value as stored under the configured PII mode:
+as found, redacted, or as an HMAC-SHA256 keyed with PII_HASH_KEY.
+*/
+type PIIMatch struct {
+	Type    string                      `json:"type"`    // email, phone or the name of a custom pattern
+	Value   string                      `json:"value"`   // Stored value (plain, redacted or hashed)
+	Sources datatypes.JSONSlice[string] `json:"sources"` // Where it was found: text, mailto, tel
+	Count   int                         `json:"count"`   // Occurrences on the page
+}
@@ -0,0 +1,35 @@
+package models
+
+/*
+RequestProfile customizes the outbound requests made while analyzing a URL:
+the User-Agent and Referer sent everywhere, plus headers, cookies and
+credentials sent only to the analyzed page's host.
+
+Profiles contain secrets, so they are never serialized in API responses.
+They are stored encrypted in URL.RequestProfile and decrypted by the
+analyzer just before use.
+*/
+type RequestProfile struct {
+	UserAgent string            `json:"userAgent,omitempty"` // Overrides the default analyzer User-Agent
+	Referer   string            `json:"referer,omitempty"`   // Referer header sent with every request
+	Headers   map[string]string `json:"headers,omitempty"`   // Extra request headers (page host only)
+	Cookies   map[string]string `json:"cookies,omitempty"`   // Cookies by name (page host only)
+	Auth      *RequestAuth      `json:"auth,omitempty"`      // Basic or bearer credentials (page host only)
+	Proxy     *ProxySettings    `json:"proxy,omitempty"`     // Overrides the global outbound proxy
+}
+
+// RequestAuth holds the credentials of a request profile.
+type RequestAuth struct {
+	Type     string `json:"type"`               // basic or bearer
+	Username string `json:"username,omitempty"` // Basic auth user name
+	Password string `json:"password,omitempty"` // Basic auth password
+	Token    string `json:"token,omitempty"`    // Bearer token
+}
+
+// ProxySettings routes a URL's analysis traffic through an HTTP, HTTPS or SOCKS5 proxy.
+type ProxySettings struct {
+	URL      string   `json:"url"`                // e.g. http://proxy:3128 or socks5://proxy:1080
+	Username string   `json:"username,omitempty"` // Proxy auth user name
+	Password string   `json:"password,omitempty"` // Proxy auth password
+	NoProxy  []string `json:"noProxy,omitempty"`  // Hosts, domains (.example.com) or CIDRs reached directly
+}
@@ -0,0 +1,34 @@
+package models
+
+/*
+Resource describes a single subresource reference found on an analyzed page,
+such as a script, stylesheet, image, iframe, form target or media source.
+
+References are resolved against the final page URL before they are stored.
+MixedContent is set to "active" or "passive" when an HTTPS page loads the
+resource over plain HTTP, and left empty otherwise.
+
+When subresource fetching is enabled, the response metadata fields are
+filled from a HEAD (or size-limited GET) request advertising compression
+support; otherwise they stay empty.
+
+Resources are embedded as JSON inside the URL record rather than stored in
+a table of their own.
+*/
+type Resource struct {
+	URL          string `json:"url"`                    // Absolute URL of the resource
+	Host         string `json:"host"`                   // Hostname the resource is served from
+	Tag          string `json:"tag"`                    // HTML tag the reference was found on
+	Attribute    string `json:"attribute"`              // Attribute holding the reference (src, href, action...)
+	Type         string `json:"type"`                   // script, stylesheet, image, icon, font, media, iframe, form, object, manifest
+	MixedContent string `json:"mixedContent,omitempty"` // active, passive or empty if not mixed
+
+	Fetched     bool   `json:"fetched"`               // Whether the resource was requested
+	StatusCode  int    `json:"statusCode,omitempty"`  // HTTP status of the fetch
+	Size        int64  `json:"size"`                  // Size in bytes as transferred, -1 if unknown
+	ContentType string `json:"contentType,omitempty"` // Content-Type response header
+	FetchError  string `json:"fetchError,omitempty"`  // Network error, if the fetch failed
+
+	// Caching/compression headers and audit findings
+	CacheHeaders
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+/*
+RunDiff records what changed on a page between two consecutive successful
+analysis runs of the same URL.
+
+It is computed when a run completes (or on first request for older runs)
+and stored so that change history can be listed without re-comparing the
+full results.
+*/
+type RunDiff struct {
+	ID                  uint                             `gorm:"primaryKey" json:"id"`          // Auto-increment primary key
+	URLID               string                           `gorm:"index" json:"urlId"`            // URL both runs belong to
+	RunID               string                           `gorm:"uniqueIndex" json:"runId"`      // Newer run
+	PreviousRunID       string                           `json:"previousRunId"`                 // Older run compared against
+	Changed             bool                             `gorm:"index" json:"changed"`          // Whether anything below differs
+	TitleChanged        bool                             `json:"titleChanged"`                  // Whether the <title> differs
+	PreviousTitle       string                           `json:"previousTitle,omitempty"`       // Title in the older run
+	Title               string                           `json:"title,omitempty"`               // Title in the newer run
+	HeadingsAdded       datatypes.JSONSlice[Heading]     `json:"headingsAdded"`                 // Headings only in the newer run
+	HeadingsRemoved     datatypes.JSONSlice[Heading]     `json:"headingsRemoved"`               // Headings only in the older run
+	LinksAdded          datatypes.JSONSlice[string]      `json:"linksAdded"`                    // Link targets only in the newer run
+	LinksRemoved        datatypes.JSONSlice[string]      `json:"linksRemoved"`                  // Link targets only in the older run
+	NewBrokenLinks      datatypes.JSONSlice[string]      `json:"newBrokenLinks"`                // Links inaccessible now but not before
+	MetadataChanges     datatypes.JSONSlice[FieldChange] `json:"metadataChanges"`               // Changed meta tags, canonical URL...
+	ContentHashChanged  bool                             `json:"contentHashChanged"`            // Whether the HTML bytes differ
+	PreviousContentHash string                           `json:"previousContentHash,omitempty"` // SHA-256 of the older HTML
+	ContentHash         string                           `json:"contentHash,omitempty"`         // SHA-256 of the newer HTML
+	CreatedAt           time.Time                        `json:"created_at"`                    // Timestamp when the diff was computed
+}
+
+// FieldChange is a single value that differs between two runs; empty means absent.
+type FieldChange struct {
+	Field    string `json:"field"`    // e.g. canonical, meta:description
+	Previous string `json:"previous"` // Value in the older run
+	Current  string `json:"current"`  // Value in the newer run
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+/*
+Sitemap records one sitemap import: the submitted sitemap (or site) URL,
+the progress of fetching every sitemap file reachable from it, and
+counters summarizing the listed pages.
+
+Each listed page is stored as a SitemapEntry and queued for analysis as a
+regular URL record.
+*/
+type Sitemap struct {
+	ID          string         `gorm:"primaryKey" json:"id"` // Unique UUID primary key
+	URL         string         `json:"url"`                  // Sitemap or site URL submitted for import
+	Status      string         `gorm:"index" json:"status"`  // running, done, error
+	ErrorReason string         `json:"errorReason"`          // If the import failed, reason string
+	Files       int            `json:"files"`                // Sitemap files fetched (including indexes)
+	URLsFound   int            `json:"urlsFound"`            // Page URLs listed across all files
+	URLsQueued  int            `json:"urlsQueued"`           // Page URLs queued for analysis
+	NotFound    int            `json:"notFound"`             // Listed URLs answering 404 or 410
+	Redirected  int            `json:"redirected"`           // Listed URLs answering with a redirect
+	Entries     []SitemapEntry `json:"entries,omitempty"`    // Listed pages, loaded on the detail endpoint
+	CreatedAt   time.Time      `json:"created_at"`           // Timestamp when the import was submitted
+	FinishedAt  *time.Time     `json:"finishedAt,omitempty"` // Timestamp when the import completed
+}
+
+/*
+SitemapEntry is a single <url> listed in an imported sitemap, with its
+lastmod/priority/changefreq values preserved as published and the result
+of probing the URL without following redirects.
+*/
+type SitemapEntry struct {
+	ID         uint     `gorm:"primaryKey" json:"id"`         // Auto-increment primary key
+	SitemapID  string   `gorm:"index" json:"sitemapId"`       // Parent import
+	Source     string   `json:"source"`                       // Sitemap file listing the URL
+	Loc        string   `json:"loc"`                          // Listed page URL
+	LastMod    string   `json:"lastmod,omitempty"`            // <lastmod> as published
+	ChangeFreq string   `json:"changefreq,omitempty"`         // <changefreq> as published
+	Priority   *float64 `json:"priority,omitempty"`           // <priority>, if present
+	StatusCode int      `json:"statusCode"`                   // Status returned for Loc (redirects not followed)
+	Location   string   `json:"location,omitempty"`           // Redirect target, if any
+	Error      string   `json:"error,omitempty"`              // Network error while probing Loc
+	URLID      string   `gorm:"index" json:"urlId,omitempty"` // URL record queued for analysis
+}
+
+/*
+BeforeCreate is a GORM lifecycle hook that runs before insertion.
+
+Ensures every Sitemap record gets a UUID assigned as ID.
+*/
+func (s *Sitemap) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID = uuid.NewString()
+	}
+	return
+}
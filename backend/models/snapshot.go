@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+/*
+Snapshot records what the server returned for the page fetch of one
+analysis run: the response headers and (up to a size limit) the body.
+
+The bytes themselves live in the configured blob store, addressed by the
+SHA-256 of their content, so identical responses across runs share a
+single stored copy. Snapshots are kept exactly as received, so the body
+blob matches the run's ContentHash, unless SNAPSHOT_REDACT_PII is set: PII
+in the stored headers and text bodies is then masked, and PDF bodies,
+which cannot be masked, are not kept.
+*/
+type Snapshot struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`     // Auto-increment primary key
+	RunID         string    `gorm:"uniqueIndex" json:"runId"` // Run whose page fetch was captured
+	URLID         string    `gorm:"index" json:"urlId"`       // URL the run belongs to
+	FinalURL      string    `json:"finalUrl"`                 // URL after redirects
+	StatusCode    int       `json:"statusCode"`               // HTTP status of the final response
+	ContentType   string    `json:"contentType"`              // Content-Type of the final response
+	BodyHash      string    `gorm:"index" json:"bodyHash"`    // Blob key of the body
+	BodySize      int64     `json:"bodySize"`                 // Stored body bytes
+	BodyTruncated bool      `json:"bodyTruncated"`            // Whether the body exceeded the size limit
+	BodyRedacted  bool      `json:"bodyRedacted"`             // Whether PII was masked (SNAPSHOT_REDACT_PII)
+	BodyOmitted   bool      `json:"bodyOmitted"`              // Whether the body was dropped because it could not be masked
+	HeadersHash   string    `gorm:"index" json:"headersHash"` // Blob key of the status line and headers
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`  // Timestamp when the snapshot was stored
+}
+
+/*
+ResponseCapture holds the raw page response while an analysis runs, until
+it is written to the blob store as a Snapshot. It is never persisted on
+its own.
+*/
+type ResponseCapture struct {
+	FinalURL    string
+	StatusCode  int
+	ContentType string
+	Headers     []byte // Status line and headers in HTTP/1.1 wire format
+	Body        []byte
+	Truncated   bool
+}
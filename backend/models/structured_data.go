@@ -0,0 +1,22 @@
+package models
+
+/*
+StructuredItem is a top-level schema.org entity found on an analyzed page
+as JSON-LD, Microdata or RDFa.
+
+Properties holds the entity as a JSON object; nested entities are objects
+carrying their own "@type". Missing lists the properties required for the
+entity's type that are absent or empty.
+*/
+type StructuredItem struct {
+	Format     string                 `json:"format"`            // json-ld, microdata or rdfa
+	Type       string                 `json:"type"`              // schema.org type without vocabulary prefix, e.g. Product
+	Properties map[string]interface{} `json:"properties"`        // Entity properties
+	Missing    []string               `json:"missing,omitempty"` // Required properties not present
+	Error      string                 `json:"error,omitempty"`   // JSON-LD parse error
+}
+
+// Valid reports whether the item parsed and has every required property.
+func (i StructuredItem) Valid() bool {
+	return i.Error == "" && len(i.Missing) == 0
+}
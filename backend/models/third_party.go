@@ -0,0 +1,18 @@
+package models
+
+/*
+ThirdPartyHost summarizes one external host an analyzed page talks to,
+either through hyperlinks or through subresources it loads.
+
+Hosts are considered third-party when their registrable domain (eTLD+1)
+differs from the page's. Company and Categories are filled in when the
+host matches an entry of the loaded tracker list.
+*/
+type ThirdPartyHost struct {
+	Host       string   `json:"host"`                 // Full hostname, e.g. www.google-analytics.com
+	Domain     string   `json:"domain"`               // Registrable domain, e.g. google-analytics.com
+	Company    string   `json:"company,omitempty"`    // Owning company according to the tracker list
+	Categories []string `json:"categories,omitempty"` // analytics, advertising, social, cdn...
+	Links      int      `json:"links"`                // Number of hyperlinks pointing at the host
+	Resources  int      `json:"resources"`            // Number of subresources loaded from the host
+}
@@ -0,0 +1,20 @@
+package models
+
+/*
+Timing breaks down how long a single fetch took, in milliseconds.
+
+Connection phases (DNS, Connect, TLS) are zero when a pooled connection
+was reused. TTFB is measured from the moment a connection was requested
+until the first response byte; Download covers reading the body. Phases
+of every redirect hop are summed, while TTFB and Download refer to the
+final response.
+*/
+type Timing struct {
+	DNSMs      float64 `json:"dnsMs"`      // DNS lookup
+	ConnectMs  float64 `json:"connectMs"`  // TCP connect (including proxy connect)
+	TLSMs      float64 `json:"tlsMs"`      // TLS handshake
+	TTFBMs     float64 `json:"ttfbMs"`     // Time to first response byte
+	DownloadMs float64 `json:"downloadMs"` // Reading the response body
+	TotalMs    float64 `json:"totalMs"`    // From connection request to body read
+	Reused     bool    `json:"reused"`     // Whether a pooled connection was reused
+}
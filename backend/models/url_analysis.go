@@ -10,37 +10,46 @@ import (
 )
 
 /*
-URL represents the metadata and crawl results for a submitted website.
+URL represents a submitted website and a snapshot of its latest analysis.
 
 This model stores:
 - the original and normalized URL,
 - status of analysis (queued, running, done, error),
-- page structure details (headings, links),
-- login form detection,
-- error details if the analysis fails.
+- job type and, for crawl pages, the parent crawl and link depth,
+- for same-origin frames analyzed as child pages, the embedding page,
+- the results of the latest analysis run (see AnalysisResult),
+- error details if the analysis fails,
+- an optional encrypted request profile (never serialized),
+- an optional recurring schedule (fixed interval or cron expression).
+
+Every run is also recorded as an AnalysisRun, so earlier results remain
+available after re-analysis.
 
 Fields are serialized to JSON and mapped to GORM-managed MySQL columns.
 */
 type URL struct {
-	ID                     string    `gorm:"primaryKey" json:"id"`                 // Unique UUID primary key
-	URL                    string    `json:"url"`                                  // Raw URL input from user
-	NormalizedURL          string    `gorm:"index" json:"normalized_url"`          // Normalized version for deduplication
-	PageTitle              string    `json:"pageTitle"`                            // Extracted <title> from the page
-	HTMLVersion            string    `json:"htmlVersion"`                          // Detected HTML doctype/version
-	InternalLinksCount     int       `json:"internalLinks"`                        // Number of internal links on page
-	ExternalLinksCount     int       `json:"externalLinks"`                        // Number of external links
-	InaccessibleLinksCount int       `json:"inaccessibleLinks"`                    // Links that failed to load
-	HasLoginForm           bool      `json:"hasLoginForm"`                         // Presence of a login form
-	H1                     int       `json:"h1"`                                   // Count of <h1> tags
-	H2                     int       `json:"h2"`                                   // Count of <h2> tags
-	H3                     int       `json:"h3"`                                   // Count of <h3> tags
-	H4                     int       `json:"h4"`                                   // Count of <h4> tags
-	H5                     int       `json:"h5"`                                   // Count of <h5> tags
-	H6                     int       `json:"h6"`                                   // Count of <h6> tags
-	ErrorReason            string    `json:"errorReason"`                          // If failed, reason string
-	ErrorCode              int       `json:"errorCode"`                            // HTTP or custom error code (e.g. 408)
-	Status                 string    `gorm:"index" json:"status"`                  // queued, running, done, error
-	CreatedAt              time.Time `json:"created_at"`                           // Timestamp when URL was submitted
+	ID                string     `gorm:"primaryKey" json:"id"`               // Unique UUID primary key
+	URL               string     `json:"url"`                                // Raw URL input from user
+	NormalizedURL     string     `gorm:"index" json:"normalized_url"`        // Normalized version for deduplication
+	JobType           string     `gorm:"default:page" json:"jobType"`        // page or crawl
+	CrawlID           *string    `gorm:"index" json:"crawlId,omitempty"`     // Parent crawl, if the page belongs to one
+	CrawlDepth        int        `json:"crawlDepth"`                         // Link hops from the crawl's start page
+	ParentURLID       *string    `gorm:"index" json:"parentUrlId,omitempty"` // Page embedding this one as a same-origin frame
+	LatestRunID       string     `gorm:"index" json:"latestRunId,omitempty"` // AnalysisRun that produced the results
+	ErrorReason       string     `json:"errorReason"`                        // If failed, reason string
+	ErrorCode         int        `json:"errorCode"`                          // HTTP or custom error code (e.g. 408)
+	RequestProfile    []byte     `json:"-"`                                  // AES-GCM encrypted RequestProfile, if any
+	HasRequestProfile bool       `json:"hasRequestProfile"`                  // Whether a request profile is stored
+	Status            string     `gorm:"index" json:"status"`                // queued, running, done, error
+	ScheduleInterval  int        `json:"scheduleInterval,omitempty"`         // Seconds between scheduled runs
+	ScheduleCron      string     `json:"scheduleCron,omitempty"`             // Cron expression for scheduled runs
+	SchedulePaused    bool       `json:"schedulePaused"`                     // Whether the schedule is paused
+	NextRunAt         *time.Time `gorm:"index" json:"nextRunAt,omitempty"`   // Next scheduled analysis
+	LastRunAt         *time.Time `json:"lastRunAt,omitempty"`                // When the latest analysis finished
+	CreatedAt         time.Time  `json:"created_at"`                         // Timestamp when URL was submitted
+
+	// Results of the latest analysis run, flattened into the URL row and JSON
+	AnalysisResult `gorm:"embedded"`
 }
 
 /*
@@ -55,3 +64,13 @@ func (u *URL) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+/*
+ResetAnalysis clears the analysis results and error on the URL so that a
+fresh analysis starts from a clean record. Identity, job and schedule
+fields are left untouched.
+*/
+func (u *URL) ResetAnalysis() {
+	u.AnalysisResult = AnalysisResult{}
+	u.ErrorReason = ""
+	u.ErrorCode = 0
+}
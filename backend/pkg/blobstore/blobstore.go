@@ -0,0 +1,46 @@
+// Package blobstore provides a small content-addressed blob storage
+// abstraction with a local filesystem backend and an S3-compatible one.
+// Blobs are immutable and keyed by the hex SHA-256 of their content, so
+// storing the same bytes twice keeps a single copy.
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when a blob does not exist in the store.
+var ErrNotFound = errors.New("blob not found")
+
+/*
+Store is a content-addressed blob store.
+
+Implementations must be safe for concurrent use. Put is idempotent: writing
+a key that already exists leaves the stored blob untouched.
+*/
+type Store interface {
+	// Put stores data under key unless a blob with that key already exists.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Open returns a reader for the blob, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob; deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Key returns the content address of data: its lowercase hex SHA-256.
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// validKey reports whether key looks like a content address produced by Key.
+func validKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
@@ -0,0 +1,90 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Options configures an S3-compatible store (AWS S3, MinIO, R2...).
+type S3Options struct {
+	Endpoint  string // Host and optional port, e.g. s3.amazonaws.com or minio:9000
+	Bucket    string // Existing bucket receiving the blobs
+	Prefix    string // Optional key prefix inside the bucket
+	Region    string // Bucket region, if the service requires one
+	AccessKey string
+	SecretKey string
+	UseSSL    bool // Connect over HTTPS
+}
+
+// S3 stores blobs as objects in an S3-compatible bucket.
+type S3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3 returns a store writing to the configured bucket.
+func NewS3(opts S3Options) (*S3, error) {
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, errors.New("S3 endpoint and bucket are required")
+	}
+	client, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure: opts.UseSSL,
+		Region: opts.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3{client: client, bucket: opts.Bucket, prefix: opts.Prefix}, nil
+}
+
+func (s *S3) object(key string) (string, error) {
+	if !validKey(key) {
+		return "", errors.New("invalid blob key")
+	}
+	return path.Join(s.prefix, key[:2], key), nil
+}
+
+// Put skips the upload when an object with the same key already exists.
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	name, err := s.object(key)
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.StatObject(ctx, s.bucket, name, minio.StatObjectOptions{}); err == nil {
+		return nil
+	}
+	_, err = s.client.PutObject(ctx, s.bucket, name, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.object(key)
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; stat first so missing blobs surface as ErrNotFound
+	if _, err := s.client.StatObject(ctx, s.bucket, name, minio.StatObjectOptions{}); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return s.client.GetObject(ctx, s.bucket, name, minio.GetObjectOptions{})
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	name, err := s.object(key)
+	if err != nil {
+		return err
+	}
+	return s.client.RemoveObject(ctx, s.bucket, name, minio.RemoveObjectOptions{})
+}
@@ -3,38 +3,117 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/DMequanint/url-analyzer-pro/config"
 	"github.com/DMequanint/url-analyzer-pro/models"
 )
 
+// runDetailColumns are the JSON result columns omitted when listing runs.
+var runDetailColumns = []string{
+	"links", "mixed_content", "subresources", "third_parties", "headings", "meta", "top_keywords",
+	"hreflang_alternates", "structured_data", "images", "discovered", "pii", "cookies",
+	"csp_policies", "csp_findings", "embeds",
+}
+
 /*
-GetAllUrlAnalyses retrieves all URL analysis records from the database.
+GetRunsByURLID retrieves the analysis runs of a URL, newest first.
 
-Each record is preloaded with its related BrokenLinks (if any).
-Returns the list of results or an error if the query fails.
+At most limit runs are returned; the bulky per-item result lists are
+omitted, use GetRunByID for a complete run.
 */
-func GetAllUrlAnalyses() ([]models.UrlAnalysis, error) {
-	var analyses []models.UrlAnalysis
-	err := config.DB.Preload("BrokenLinks").Find(&analyses).Error
-	return analyses, err
+func GetRunsByURLID(urlID string, limit int) ([]models.AnalysisRun, error) {
+	var runs []models.AnalysisRun
+	err := config.DB.Omit(runDetailColumns...).
+		Where("url_id = ?", urlID).
+		Order("started_at desc").
+		Limit(limit).
+		Find(&runs).Error
+	return runs, err
 }
 
 /*
-GetUrlAnalysisByID retrieves a single URL analysis record by its numeric ID.
+GetRunByID retrieves a single analysis run of a URL.
 
-Returns the record with preloaded BrokenLinks or an error if not found.
+Returns an error if the run does not exist or belongs to another URL.
 */
-func GetUrlAnalysisByID(id uint) (models.UrlAnalysis, error) {
-	var analysis models.UrlAnalysis
-	err := config.DB.Preload("BrokenLinks").First(&analysis, id).Error
-	return analysis, err
+func GetRunByID(urlID, runID string) (models.AnalysisRun, error) {
+	var run models.AnalysisRun
+	err := config.DB.First(&run, "id = ? AND url_id = ?", runID, urlID).Error
+	return run, err
 }
 
 /*
-CreateUrlAnalysis inserts a new analysis record into the database.
+StartRun inserts a new "running" analysis run for the given URL.
+
+Returns the created run or an error encountered during insertion.
+*/
+func StartRun(u *models.URL) (*models.AnalysisRun, error) {
+	run := &models.AnalysisRun{
+		URLID:     u.ID,
+		Status:    "running",
+		StartedAt: time.Now(),
+	}
+	err := config.DB.Create(run).Error
+	return run, err
+}
 
-Returns any error encountered during insertion.
+/*
+FinishRun copies the URL's final status, error and results into the run,
+records its timing and saves it.
+*/
+func FinishRun(run *models.AnalysisRun, u *models.URL) error {
+	finished := time.Now()
+	run.Status = u.Status
+	run.ErrorReason = u.ErrorReason
+	run.ErrorCode = u.ErrorCode
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(run.StartedAt).Milliseconds()
+	run.AnalysisResult = u.AnalysisResult
+	return config.DB.Save(run).Error
+}
+
+/*
+DeleteRunsByURLID removes every analysis run recorded for a URL, together
+with the diffs between them.
+*/
+func DeleteRunsByURLID(urlID string) error {
+	if err := config.DB.Where("url_id = ?", urlID).Delete(&models.RunDiff{}).Error; err != nil {
+		return err
+	}
+	return config.DB.Where("url_id = ?", urlID).Delete(&models.AnalysisRun{}).Error
+}
+
+/*
+urlAPIColumns are the URL columns owned by the API: identity, job, request
+profile and schedule. The analysis worker never writes them, so changes
+made while a run is in flight (e.g. pausing its schedule) are kept.
+*/
+var urlAPIColumns = []string{
+	"id", "url", "normalized_url", "job_type", "crawl_id", "crawl_depth", "parent_url_id",
+	"request_profile", "has_request_profile",
+	"schedule_interval", "schedule_cron", "schedule_paused", "next_run_at", "created_at",
+}
+
+// MarkURLRunning records that the URL is being analyzed by the given run.
+func MarkURLRunning(u *models.URL) error {
+	return config.DB.Model(&models.URL{}).Where("id = ?", u.ID).
+		Updates(map[string]interface{}{"status": u.Status, "latest_run_id": u.LatestRunID}).Error
+}
+
+/*
+SaveURLAnalysis writes the status, error, results and run bookkeeping of
+a finished analysis to the URL, leaving the columns in urlAPIColumns as
+they are in the database. Unlike Save, it never inserts: found is false
+when the URL was deleted while it was being analyzed.
 */
-func CreateUrlAnalysis(analysis *models.UrlAnalysis) error {
-	return config.DB.Create(analysis).Error
+func SaveURLAnalysis(u *models.URL) (found bool, err error) {
+	res := config.DB.Model(&models.URL{}).Where("id = ?", u.ID).Select("*").Omit(urlAPIColumns...).Updates(u)
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.RowsAffected > 0, res.Error
+	}
+	// MySQL reports unchanged rows as unaffected, so check the row still exists
+	var count int64
+	err = config.DB.Model(&models.URL{}).Where("id = ?", u.ID).Count(&count).Error
+	return count > 0, err
 }
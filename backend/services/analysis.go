@@ -4,10 +4,16 @@
 package services
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"io"
 	"net/http"
 	"strings"
 
+	"github.com/DMequanint/url-analyzer-pro/config"
 	"github.com/DMequanint/url-analyzer-pro/models"
 	"golang.org/x/net/html"
 )
@@ -16,41 +22,132 @@ import (
 AnalyzeURL performs a structured crawl of the given URL and populates
 its analysis result into the provided *models.URL object.
 
-The function sends an HTTP GET request to the target URL, and if successful:
-  - Parses the HTML document
+The function sends an HTTP GET request to the target URL, using the URL's
+request profile (User-Agent, headers, cookies, credentials) when one is
+stored, and if successful:
+  - Parses the HTML document, or hands PDF and plain-text documents to
+    their own analyzers (see analyzeDocument)
   - Counts heading tags (h1-h6)
-  - Counts internal and external hyperlinks
+  - Counts internal and external hyperlinks, optionally checking each one
   - Detects presence of a login form by checking for password input fields
-  - Extracts the document title
+  - Extracts the document title, heading texts, meta tags and canonical URL
+  - Measures the visible text: word and sentence counts, readability,
+    text-to-HTML ratio and top keywords
+  - Compares the declared language with the one detected from the text and
+    validates hreflang alternates
+  - Extracts JSON-LD, Microdata and RDFa entities and checks required
+    properties for common schema.org types
+  - Records a timing breakdown of the page fetch
+  - Hashes the HTML document for change detection and captures the raw
+    response for the run's snapshot
+  - Collects subresource references and flags mixed content on HTTPS pages
+  - Builds a subresource inventory and estimates the total page weight
+  - Audits caching and compression headers of the page and subresources
+  - Inventories images (img, picture, CSS backgrounds) and flags missing
+    alt text, oversized files and legacy formats
+  - Discovers feeds, web app manifests, icons and OpenSearch descriptors,
+    optionally fetching and validating them
+  - Parses and evaluates the Content Security Policy, cross-checked
+    against the page's inline scripts and subresources
+  - Inventories cookies set along the redirect chain and by subresources,
+    flagging insecure attributes
+  - Detects exposed emails, phone numbers and custom PII patterns, stored
+    plain, redacted or hashed
+  - Lists third-party hosts and matches them against the tracker list
   - Determines a basic HTML version based on the HTTP protocol
 
-Returns an error if the URL is unreachable, HTTP fails, or parsing fails.
+Every outbound request is bound to ctx, so cancelling it abandons the
+analysis promptly. Returns an error if the URL is disallowed by
+robots.txt (under the "obey" policy), unreachable, HTTP fails, or
+parsing fails.
 */
-func AnalyzeURL(u *models.URL) error {
-	// Step 1: HTTP GET request
-	resp, err := http.Get(u.URL)
+func AnalyzeURL(ctx context.Context, u *models.URL) error {
+	// Start from a clean result; the previous run stays in its AnalysisRun
+	u.ResetAnalysis()
+
+	profile, err := DecryptRequestProfile(u.RequestProfile)
 	if err != nil {
 		return err
 	}
+	client := newHTTPClient(profile, u.URL)
+	defer client.CloseIdleConnections()
+
+	// Step 1: Consult robots.txt, then send the HTTP GET request
+	verdict, proceed, err := robotsCheck(ctx, client, u.URL)
+	u.RobotsVerdict = verdict
+	u.RobotsError = ""
+	if err != nil {
+		if !proceed {
+			return err
+		}
+		u.RobotsError = err.Error()
+	}
+	if !proceed {
+		return errors.New("blocked by robots.txt")
+	}
+
+	// The page fetch is traced for its DNS/connect/TLS/TTFB/download breakdown,
+	// and cookies set along its redirect chain are recorded
+	timer := &fetchTimer{}
+	cookies := &cookieRecorder{}
+	pageCtx := withCookieRecorder(ctx, cookies, cookieSourcePage)
+	req, err := http.NewRequestWithContext(timer.withTrace(pageCtx), http.MethodGet, u.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		u.PageTiming = timer.finish()
+		return err
+	}
 	defer resp.Body.Close()
 
+	// Keep a copy of what the server returned for the run's snapshot
+	capture := newCapture(resp)
+	u.Capture = capture
+
 	if resp.StatusCode >= 400 {
+		captureErrorBody(capture, resp.Body)
+		u.PageTiming = timer.finish()
 		return errors.New("unreachable: " + resp.Status)
 	}
 
-	// Step 2: Parse HTML
-	doc, err := html.Parse(resp.Body)
+	// Step 2: Parse HTML, counting, hashing and capturing the document bytes;
+	// PDF and plain-text documents take their own path
+	hash := sha256.New()
+	body := &countingReader{r: io.TeeReader(resp.Body, io.MultiWriter(hash, captureWriter{capture})), onEnd: timer.stop}
+	buffered := bufio.NewReader(body)
+	head, _ := buffered.Peek(sniffLen)
+	u.DocumentType = detectDocumentType(resp.Header.Get("Content-Type"), head)
+	if u.DocumentType != DocumentHTML {
+		return analyzeDocument(ctx, u, client, resp, buffered, body, hash, timer, cookies)
+	}
+	doc, err := html.Parse(buffered)
+	u.PageTiming = timer.finish()
 	if err != nil {
 		return err
 	}
 
+	// Release the connection now: link checks may need a slot for the same host
+	resp.Body.Close()
+
 	// Step 3: Initialize counters
 	headings := map[string]int{}
 	linkCount := 0
 	internal := 0
 	external := 0
-	inaccessible := 0
 	hasLogin := false
+	var resources []models.Resource
+	var hrefs []string
+	var headingList []models.Heading
+	var meta []models.MetaTag
+	var alternates []models.HreflangAlternate
+	var discovered []models.DiscoveredAsset
+	canonical := ""
+	htmlLang := ""
+
+	// Subresource references are resolved against the final URL after redirects
+	pageURL := resp.Request.URL.String()
 
 	// Step 4: Recursive DOM traversal
 	var f func(*html.Node)
@@ -59,8 +156,17 @@ func AnalyzeURL(u *models.URL) error {
 			tag := strings.ToLower(n.Data) // normalize tag name here
 
 			switch tag {
+			case "html":
+				htmlLang = attrValue(n, "lang")
+
 			case "h1", "h2", "h3", "h4", "h5", "h6":
 				headings[tag]++ // ✅ NOW SAFE — normalized to lowercase
+				headingList = append(headingList, models.Heading{Level: int(tag[1] - '0'), Text: nodeText(n)})
+
+			case "meta":
+				if m, ok := metaTag(n); ok {
+					meta = append(meta, m)
+				}
 
 			case "a":
 				for _, attr := range n.Attr {
@@ -72,7 +178,9 @@ func AnalyzeURL(u *models.URL) error {
 						} else {
 							internal++
 						}
-						// TODO: check if href is accessible and count `inaccessible`
+						if abs := resolveURL(pageURL, href); hasSchemePrefix(abs, "http:", "https:") {
+							hrefs = append(hrefs, abs)
+						}
 					}
 				}
 
@@ -87,6 +195,19 @@ func AnalyzeURL(u *models.URL) error {
 				if n.FirstChild != nil && strings.TrimSpace(u.PageTitle) == "" {
 					u.PageTitle = strings.TrimSpace(n.FirstChild.Data)
 				}
+
+			case "script", "link", "img", "iframe", "frame", "form",
+				"video", "audio", "source", "track", "embed", "object":
+				if tag == "link" && canonical == "" && hasRel(n, "canonical") {
+					canonical = resolveURL(pageURL, strings.TrimSpace(attrValue(n, "href")))
+				}
+				if alt, ok := hreflangRef(n, pageURL); ok && tag == "link" {
+					alternates = append(alternates, alt)
+				}
+				if asset, ok := discoveryRef(n, pageURL); ok && tag == "link" {
+					discovered = append(discovered, asset)
+				}
+				resources = append(resources, collectResourceRefs(n, tag, pageURL)...)
 			}
 		}
 
@@ -101,8 +222,112 @@ func AnalyzeURL(u *models.URL) error {
 	u.HTMLVersion = detectHTMLVersion(resp.Proto)
 	u.InternalLinksCount = internal
 	u.ExternalLinksCount = external
-	u.InaccessibleLinksCount = inaccessible
 	u.HasLoginForm = hasLogin
+	u.Headings = headingList
+	u.Meta = meta
+	u.CanonicalURL = canonical
+	u.ContentHash = hex.EncodeToString(hash.Sum(nil))
+	u.PageCache = cacheHeadersFrom(resp)
+	u.PageCache.CacheIssues = auditCacheHeaders(u.PageCache, resp.Header.Get("Content-Type"), body.n, false)
+
+	// Content metrics and language of the readable body text
+	text := visibleText(doc)
+	applyTextMetrics(&u.AnalysisResult, text, body.n)
+	applyLanguage(&u.AnalysisResult, htmlLang, resp.Header.Get("Content-Language"), text)
+	u.PII = extractPII(doc, text)
+	u.PIICount = len(u.PII)
+	applyHreflang(ctx, &u.AnalysisResult, client, []string{u.URL, pageURL, canonical}, alternates)
+
+	// Feeds, manifests, icons and OpenSearch descriptors announced in <link> tags
+	applyDiscovery(ctx, &u.AnalysisResult, client, pageURL, discovered)
+
+	// schema.org entities from JSON-LD, Microdata and RDFa
+	structured := extractStructuredData(doc, pageURL)
+	u.StructuredDataCount = len(structured)
+	u.StructuredDataInvalid = 0
+	for _, item := range structured {
+		if !item.Valid() {
+			u.StructuredDataInvalid++
+		}
+	}
+	u.StructuredData = structured
+
+	// Images, including CSS backgrounds which also count as subresources
+	images := collectImages(doc, pageURL)
+	resources = append(resources, cssImageRefs(images)...)
+
+	// Link checking respects the robots.txt policy for every target
+	applyLinks(ctx, &u.AnalysisResult, client, resp.Request.URL.Hostname(), hrefs)
+
+	// Mixed content only applies to pages served over HTTPS
+	mixed := []models.Resource{}
+	u.MixedContentActive = 0
+	u.MixedContentPassive = 0
+	for _, r := range resources {
+		r.MixedContent = classifyMixedContent(resp.Request.URL.Scheme, r)
+		switch r.MixedContent {
+		case MixedContentActive:
+			u.MixedContentActive++
+		case MixedContentPassive:
+			u.MixedContentPassive++
+		default:
+			continue
+		}
+		mixed = append(mixed, r)
+	}
+	u.MixedContent = mixed
+
+	// Subresource inventory: optionally fetched to estimate the page weight
+	inventory := uniqueResources(resources)
+	if config.Analyzer.FetchResources {
+		fetchResources(withCookieRecorder(ctx, cookies, cookieSourceSubresource), client, inventory)
+	}
+	u.HTMLBytes = body.n
+	u.PageWeightBytes = body.n
+	for _, r := range inventory {
+		if r.Size > 0 {
+			u.PageWeightBytes += r.Size
+		}
+	}
+	u.SubresourceCount = len(inventory)
+	u.Subresources = inventory
+
+	// Caching/compression findings of the page and every fetched subresource
+	u.CacheIssueCount = len(u.PageCache.CacheIssues)
+	for _, r := range inventory {
+		u.CacheIssueCount += len(r.CacheIssues)
+	}
+
+	// Image audit, using fetched sizes and content types where available
+	auditImages(images, inventory)
+	u.ImageCount = len(images)
+	u.ImagesMissingAlt = 0
+	u.ImageIssueCount = 0
+	u.ImageBytes = 0
+	for _, img := range images {
+		u.ImageIssueCount += len(img.Issues)
+		for _, issue := range img.Issues {
+			if issue == ImageIssueMissingAlt {
+				u.ImagesMissingAlt++
+			}
+		}
+		if img.Size > 0 {
+			u.ImageBytes += img.Size
+		}
+	}
+	u.Images = images
+
+	// Content Security Policy weaknesses and conflicts with what the page loads
+	applyCSP(&u.AnalysisResult, resp.Header, doc, pageURL, inventory)
+
+	// Iframes, frames, embeds and objects with their sandboxing and titles
+	applyEmbeds(&u.AnalysisResult, doc, pageURL)
+
+	// Cookies set by the page, its redirects and fetched subresources
+	applyCookies(&u.AnalysisResult, resp.Request.URL.Hostname(), cookies)
+
+	// Third-party hosts reached through links or subresources
+	applyThirdParties(&u.AnalysisResult, resp.Request.URL.Hostname(), hrefs, inventory)
 
 	// ✅ Fixed: heading map keys now lowercase and consistent
 	u.H1 = headings["h1"]
@@ -119,6 +344,54 @@ func AnalyzeURL(u *models.URL) error {
 	return nil
 }
 
+// applyLinks builds the unique hyperlink list of a page, checks it when enabled and counts failures.
+func applyLinks(ctx context.Context, r *models.AnalysisResult, client *http.Client, pageHost string, hrefs []string) {
+	links := buildLinks(pageHost, hrefs)
+	if config.Analyzer.CheckLinks {
+		checkLinks(ctx, client, links)
+	}
+	r.InaccessibleLinksCount = 0
+	r.RobotsBlockedLinks = 0
+	for _, l := range links {
+		if l.Inaccessible() {
+			r.InaccessibleLinksCount++
+		}
+		if l.Robots == RobotsDisallowed {
+			r.RobotsBlockedLinks++
+		}
+	}
+	r.Links = links
+}
+
+// applyCookies stores the cookie inventory of a page and its first-/third-party and issue counts.
+func applyCookies(r *models.AnalysisResult, pageHost string, rec *cookieRecorder) {
+	r.Cookies = buildCookieInventory(pageHost, rec)
+	r.CookieCount = len(r.Cookies)
+	r.ThirdPartyCookieCount = 0
+	r.InsecureCookieCount = 0
+	for _, c := range r.Cookies {
+		if c.ThirdParty {
+			r.ThirdPartyCookieCount++
+		}
+		if len(c.Issues) > 0 {
+			r.InsecureCookieCount++
+		}
+	}
+}
+
+// applyThirdParties stores the third-party host inventory of a page and counts known trackers.
+func applyThirdParties(r *models.AnalysisResult, pageHost string, hrefs []string, inventory []models.Resource) {
+	thirdParties := buildThirdPartyInventory(pageHost, hrefs, inventory)
+	r.ThirdPartyCount = len(thirdParties)
+	r.TrackerCount = 0
+	for _, h := range thirdParties {
+		if len(h.Categories) > 0 {
+			r.TrackerCount++
+		}
+	}
+	r.ThirdParties = thirdParties
+}
+
 /*
 detectHTMLVersion returns a simplified indicator of HTML version
 based on the HTTP protocol used by the server.
@@ -131,4 +404,3 @@ func detectHTMLVersion(proto string) string {
 	}
 	return "Unknown"
 }
-
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/DMequanint/url-analyzer-pro/models"
+)
+
+// Caching and compression findings recorded in CacheHeaders.CacheIssues.
+const (
+	CacheIssueUncompressed  = "uncompressed"
+	CacheIssueNoValidator   = "no-validator"
+	CacheIssueNoStoreStatic = "no-store-static"
+)
+
+// acceptEncoding is advertised on subresource probes so servers reveal their compression support.
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// minCompressibleBytes is the size below which an uncompressed text response is not flagged.
+const minCompressibleBytes = 1024
+
+// staticResourceTypes are subresource types expected to be cacheable.
+var staticResourceTypes = map[string]bool{
+	"script": true, "stylesheet": true, "image": true, "icon": true, "font": true, "media": true,
+}
+
+/*
+cacheHeadersFrom copies the caching and compression headers of resp.
+
+Go's transport transparently decompresses gzip responses it asked for and
+drops their Content-Encoding header; such responses are reported as gzip.
+*/
+func cacheHeadersFrom(resp *http.Response) models.CacheHeaders {
+	h := models.CacheHeaders{
+		CacheControl:    resp.Header.Get("Cache-Control"),
+		Expires:         resp.Header.Get("Expires"),
+		ETag:            resp.Header.Get("ETag"),
+		LastModified:    resp.Header.Get("Last-Modified"),
+		Vary:            strings.Join(resp.Header.Values("Vary"), ", "),
+		ContentEncoding: resp.Header.Get("Content-Encoding"),
+	}
+	if h.ContentEncoding == "" && resp.Uncompressed {
+		h.ContentEncoding = "gzip"
+	}
+	return h
+}
+
+/*
+auditCacheHeaders returns the caching and compression findings for a
+successful response: text bodies of at least minCompressibleBytes without
+Content-Encoding (size -1 means unknown and is flagged too), responses
+without ETag or Last-Modified, and static assets marked no-store.
+*/
+func auditCacheHeaders(h models.CacheHeaders, contentType string, size int64, static bool) []string {
+	issues := []string{}
+	encoding := strings.ToLower(strings.TrimSpace(h.ContentEncoding))
+	if isTextContentType(contentType) && (encoding == "" || encoding == "identity") &&
+		(size < 0 || size >= minCompressibleBytes) {
+		issues = append(issues, CacheIssueUncompressed)
+	}
+	if h.ETag == "" && h.LastModified == "" {
+		issues = append(issues, CacheIssueNoValidator)
+	}
+	if static && cacheDirective(h.CacheControl, "no-store") {
+		issues = append(issues, CacheIssueNoStoreStatic)
+	}
+	return issues
+}
+
+// isTextContentType reports whether a Content-Type is textual and worth compressing.
+func isTextContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/javascript", "application/x-javascript", "application/ecmascript",
+		"application/json", "application/ld+json", "application/manifest+json",
+		"application/xml", "application/xhtml+xml", "application/rss+xml", "application/atom+xml",
+		"image/svg+xml", "application/wasm":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// cacheDirective reports whether a Cache-Control value contains the given directive.
+func cacheDirective(cacheControl, directive string) bool {
+	for _, part := range strings.Split(cacheControl, ",") {
+		name := strings.TrimSpace(strings.SplitN(part, "=", 2)[0])
+		if strings.EqualFold(name, directive) {
+			return true
+		}
+	}
+	return false
+}
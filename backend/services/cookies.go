@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+)
+
+// Cookie findings recorded in Cookie.Issues.
+const (
+	CookieIssueNotSecure     = "not-secure"             // Missing Secure attribute
+	CookieIssueNotHTTPOnly   = "not-httponly"           // Readable from JavaScript
+	CookieIssueNoSameSite    = "no-samesite"            // SameSite left to the browser default
+	CookieIssueSameSiteNone  = "samesite-none-insecure" // SameSite=None without Secure, rejected by browsers
+	CookieIssueLongLived     = "long-lived"             // Expires more than cookieMaxLifetime ahead
+	CookieIssueInvalidPrefix = "invalid-prefix"         // __Secure-/__Host- prefix rules not met
+	CookieIssueForeignDomain = "foreign-domain"         // Domain does not cover the setting host
+)
+
+// Where a cookie was set, recorded in Cookie.Source.
+const (
+	cookieSourcePage        = "page"
+	cookieSourceSubresource = "subresource"
+)
+
+// cookieMaxLifetime is the longest expiry not flagged as long-lived: 13 months, the usual consent guidance.
+const cookieMaxLifetime = 395 * 24 * time.Hour
+
+// cookieRecorder collects the cookies set on the responses of requests carrying it.
+type cookieRecorder struct {
+	mu      sync.Mutex
+	cookies []models.Cookie
+}
+
+// cookieRecorderKey is the context key of a request's cookieRecording.
+type cookieRecorderKey struct{}
+
+// cookieRecording pairs a recorder with the source its cookies are attributed to.
+type cookieRecording struct {
+	recorder *cookieRecorder
+	source   string
+}
+
+// withCookieRecorder returns ctx recording Set-Cookie headers into rec, attributed to source.
+func withCookieRecorder(ctx context.Context, rec *cookieRecorder, source string) context.Context {
+	if rec == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, cookieRecorderKey{}, cookieRecording{recorder: rec, source: source})
+}
+
+/*
+cookieTransport records the cookies of every response, redirects
+included, whose request context carries a cookieRecorder.
+*/
+type cookieTransport struct {
+	base http.RoundTripper
+}
+
+func (t *cookieTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if rec, ok := req.Context().Value(cookieRecorderKey{}).(cookieRecording); ok {
+		rec.recorder.record(req.URL, resp, rec.source)
+	}
+	return resp, nil
+}
+
+// record adds the cookies set by resp, a response for reqURL.
+func (r *cookieRecorder) record(reqURL *url.URL, resp *http.Response, source string) {
+	set := resp.Cookies()
+	if len(set) == 0 {
+		return
+	}
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range set {
+		if c.MaxAge < 0 {
+			continue // deletion
+		}
+		cookie := models.Cookie{
+			Name:     c.Name,
+			Domain:   strings.ToLower(strings.TrimPrefix(c.Domain, ".")),
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HttpOnly,
+			SameSite: sameSiteName(c.SameSite),
+			Source:   source,
+			SetBy:    reqURL.String(),
+		}
+		if cookie.Domain == "" {
+			cookie.Domain = strings.ToLower(reqURL.Hostname())
+			cookie.HostOnly = true
+		}
+		if cookie.Path == "" {
+			cookie.Path = defaultCookiePath(reqURL.Path)
+		}
+		switch {
+		case c.MaxAge > 0:
+			expires := now.Add(time.Duration(c.MaxAge) * time.Second).UTC()
+			cookie.Expires = &expires
+		case !c.Expires.IsZero():
+			expires := c.Expires.UTC()
+			cookie.Expires = &expires
+		}
+		r.cookies = append(r.cookies, cookie)
+	}
+}
+
+// sameSiteName returns the SameSite attribute as written, or "" when it was not set.
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
+
+// defaultCookiePath is the path a cookie without Path attribute applies to (RFC 6265 5.1.4).
+func defaultCookiePath(requestPath string) string {
+	i := strings.LastIndex(requestPath, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return requestPath[:i]
+}
+
+/*
+buildCookieInventory de-duplicates the recorded cookies by name, domain
+and path (the last value set wins), classifies them as first- or
+third-party relative to pageHost and audits their attributes.
+*/
+func buildCookieInventory(pageHost string, rec *cookieRecorder) []models.Cookie {
+	rec.mu.Lock()
+	recorded := append([]models.Cookie(nil), rec.cookies...)
+	rec.mu.Unlock()
+
+	site := registrableDomain(pageHost)
+	index := map[string]int{}
+	cookies := []models.Cookie{}
+	now := time.Now()
+	for _, c := range recorded {
+		c.ThirdParty = registrableDomain(c.Domain) != site
+		c.Issues = auditCookie(c, now)
+
+		key := c.Name + ";" + c.Domain + ";" + c.Path
+		if i, ok := index[key]; ok {
+			cookies[i] = c
+			continue
+		}
+		index[key] = len(cookies)
+		cookies = append(cookies, c)
+	}
+
+	sort.SliceStable(cookies, func(i, j int) bool {
+		if cookies[i].Domain != cookies[j].Domain {
+			return cookies[i].Domain < cookies[j].Domain
+		}
+		return cookies[i].Name < cookies[j].Name
+	})
+	return cookies
+}
+
+// auditCookie returns the insecure or non-compliant attributes of c.
+func auditCookie(c models.Cookie, now time.Time) []string {
+	var issues []string
+	if !c.Secure {
+		issues = append(issues, CookieIssueNotSecure)
+	}
+	if !c.HTTPOnly {
+		issues = append(issues, CookieIssueNotHTTPOnly)
+	}
+	switch c.SameSite {
+	case "":
+		issues = append(issues, CookieIssueNoSameSite)
+	case "None":
+		if !c.Secure {
+			issues = append(issues, CookieIssueSameSiteNone)
+		}
+	}
+	if c.Expires != nil && c.Expires.Sub(now) > cookieMaxLifetime {
+		issues = append(issues, CookieIssueLongLived)
+	}
+	switch {
+	case strings.HasPrefix(c.Name, "__Host-"):
+		if !c.Secure || !c.HostOnly || c.Path != "/" {
+			issues = append(issues, CookieIssueInvalidPrefix)
+		}
+	case strings.HasPrefix(c.Name, "__Secure-"):
+		if !c.Secure {
+			issues = append(issues, CookieIssueInvalidPrefix)
+		}
+	}
+	if u, err := url.Parse(c.SetBy); err == nil && !domainMatches(strings.ToLower(u.Hostname()), c.Domain) {
+		issues = append(issues, CookieIssueForeignDomain)
+	}
+	return issues
+}
+
+// domainMatches reports whether host is domain or one of its subdomains.
+func domainMatches(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// CookieReportEntry aggregates one cookie (name, domain, path) across analyzed pages.
+type CookieReportEntry struct {
+	Name       string     `json:"name"`
+	Domain     string     `json:"domain"`
+	Path       string     `json:"path"`
+	ThirdParty bool       `json:"thirdParty"`
+	Secure     bool       `json:"secure"`
+	HTTPOnly   bool       `json:"httpOnly"`
+	SameSite   string     `json:"sameSite,omitempty"`
+	Expires    *time.Time `json:"expires,omitempty"` // Latest expiry seen
+	Issues     []string   `json:"issues"`            // Union of the findings on every page
+	Pages      int        `json:"pages"`             // Number of pages setting the cookie
+}
+
+/*
+CookieReport aggregates the cookie inventory of the latest analysis of
+every URL, optionally restricted to the pages of a crawl, into one entry
+per cookie for consent and compliance reviews. Entries are sorted with
+third-party cookies first, then by domain and name.
+*/
+func CookieReport(crawlID string) ([]CookieReportEntry, error) {
+	query := config.DB.Model(&models.URL{}).Where("cookie_count > 0")
+	if crawlID != "" {
+		query = query.Where("crawl_id = ?", crawlID)
+	}
+	var urls []models.URL
+	if err := query.Select("id, cookies").Find(&urls).Error; err != nil {
+		return nil, err
+	}
+
+	entries := map[string]*CookieReportEntry{}
+	for _, u := range urls {
+		seen := map[string]bool{}
+		for _, c := range u.Cookies {
+			key := c.Name + ";" + c.Domain + ";" + c.Path
+			e, ok := entries[key]
+			if !ok {
+				e = &CookieReportEntry{
+					Name:       c.Name,
+					Domain:     c.Domain,
+					Path:       c.Path,
+					ThirdParty: c.ThirdParty,
+					Secure:     c.Secure,
+					HTTPOnly:   c.HTTPOnly,
+					SameSite:   c.SameSite,
+					Issues:     []string{},
+				}
+				entries[key] = e
+			}
+			if c.Expires != nil && (e.Expires == nil || c.Expires.After(*e.Expires)) {
+				e.Expires = c.Expires
+			}
+			for _, issue := range c.Issues {
+				if !containsString(e.Issues, issue) {
+					e.Issues = append(e.Issues, issue)
+				}
+			}
+			if !seen[key] {
+				seen[key] = true
+				e.Pages++
+			}
+		}
+	}
+
+	report := make([]CookieReportEntry, 0, len(entries))
+	for _, e := range entries {
+		report = append(report, *e)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.ThirdParty != b.ThirdParty {
+			return a.ThirdParty
+		}
+		if a.Domain != b.Domain {
+			return a.Domain < b.Domain
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Path < b.Path
+	})
+	return report, nil
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/DMequanint/url-analyzer-pro/pkg/websockethub"
+)
+
+// Job types stored on each URL record.
+const (
+	JobTypePage  = "page"  // Analyze the submitted page only
+	JobTypeCrawl = "crawl" // Analyze the page and follow its internal links
+)
+
+// Upper bounds for user-supplied crawl limits.
+const (
+	maxCrawlDepth = 10
+	maxCrawlPages = 1000
+)
+
+// crawlMu serializes crawl expansion so concurrent workers never queue the same page twice.
+var crawlMu sync.Mutex
+
+// CrawlOptions are the user-supplied settings of a crawl job.
+type CrawlOptions struct {
+	MaxDepth int      `json:"maxDepth"` // Link hops from the start page (default CRAWL_MAX_DEPTH)
+	MaxPages int      `json:"maxPages"` // Pages analyzed in total (default CRAWL_MAX_PAGES)
+	Include  []string `json:"include"`  // Regexps; if set, a page URL must match one of them
+	Exclude  []string `json:"exclude"`  // Regexps; a page URL matching any of them is skipped
+}
+
+/*
+NewCrawl validates opts, applies the configured defaults and returns an
+unsaved Crawl for startURL. Depth and page limits are capped to keep a
+single request from queuing an unbounded number of pages.
+*/
+func NewCrawl(startURL string, opts CrawlOptions) (*models.Crawl, error) {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = config.Analyzer.CrawlMaxDepth
+	}
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = config.Analyzer.CrawlMaxPages
+	}
+	if opts.MaxDepth > maxCrawlDepth {
+		return nil, fmt.Errorf("maxDepth cannot exceed %d", maxCrawlDepth)
+	}
+	if opts.MaxPages > maxCrawlPages {
+		return nil, fmt.Errorf("maxPages cannot exceed %d", maxCrawlPages)
+	}
+	if _, err := compilePatterns(opts.Include); err != nil {
+		return nil, err
+	}
+	if _, err := compilePatterns(opts.Exclude); err != nil {
+		return nil, err
+	}
+
+	return &models.Crawl{
+		StartURL: startURL,
+		Status:   "running",
+		MaxDepth: opts.MaxDepth,
+		MaxPages: opts.MaxPages,
+		Include:  opts.Include,
+		Exclude:  opts.Exclude,
+	}, nil
+}
+
+/*
+ExpandCrawl queues the internal links of a freshly analyzed crawl page.
+
+Links are followed only while the page is shallower than the crawl's
+MaxDepth and the crawl has fewer than MaxPages pages. Each candidate must
+match the include patterns (if any), no exclude pattern, and the
+robots.txt policy; pages already part of the crawl are skipped after
+normalization. New pages inherit the request profile of the page that
+linked to them.
+*/
+func ExpandCrawl(u *models.URL) error {
+	if u.CrawlID == nil {
+		return nil
+	}
+
+	var crawl models.Crawl
+	if err := config.DB.First(&crawl, "id = ?", *u.CrawlID).Error; err != nil {
+		return err
+	}
+	if u.CrawlDepth >= crawl.MaxDepth {
+		return nil
+	}
+
+	include, err := compilePatterns(crawl.Include)
+	if err != nil {
+		return err
+	}
+	exclude, err := compilePatterns(crawl.Exclude)
+	if err != nil {
+		return err
+	}
+
+	// Pages known before the robots checks are skipped early; the set is read
+	// again under crawlMu, since other workers may queue pages meanwhile
+	seen, err := crawlPages(crawl.ID)
+	if err != nil {
+		return err
+	}
+
+	profile, err := DecryptRequestProfile(u.RequestProfile)
+	if err != nil {
+		return err
+	}
+	client := newHTTPClient(profile, u.URL)
+	defer client.CloseIdleConnections()
+
+	// Robots checks may wait on politeness limits, so they run outside crawlMu
+	type candidate struct {
+		url        string
+		normalized string
+	}
+	var candidates []candidate
+	known := len(seen)
+	for _, link := range u.Links {
+		if known+len(candidates) >= crawl.MaxPages {
+			break
+		}
+		if !link.Internal {
+			continue
+		}
+		normalized, err := NormalizeURL(link.URL)
+		if err != nil || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+
+		if !crawlPatternsAllow(link.URL, include, exclude) {
+			continue
+		}
+		// Pages whose robots.txt is unreachable are queued; their analysis reports the error
+		if _, proceed, err := robotsCheck(context.Background(), client, link.URL); err == nil && !proceed {
+			continue
+		}
+		candidates = append(candidates, candidate{link.URL, normalized})
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	crawlMu.Lock()
+	defer crawlMu.Unlock()
+
+	seen, err = crawlPages(crawl.ID)
+	if err != nil {
+		return err
+	}
+	total := len(seen)
+	for _, c := range candidates {
+		if total >= crawl.MaxPages {
+			break
+		}
+		if seen[c.normalized] {
+			continue
+		}
+		seen[c.normalized] = true
+
+		page := models.URL{
+			URL:               c.url,
+			NormalizedURL:     c.normalized,
+			Status:            "queued",
+			JobType:           JobTypePage,
+			CrawlID:           u.CrawlID,
+			CrawlDepth:        u.CrawlDepth + 1,
+			RequestProfile:    u.RequestProfile,
+			HasRequestProfile: u.HasRequestProfile,
+		}
+		if err := config.DB.Create(&page).Error; err != nil {
+			return err
+		}
+		total++
+
+		websockethub.BroadcastStatusUpdate(map[string]interface{}{
+			"id":      page.ID,
+			"url":     page.URL,
+			"status":  page.Status,
+			"crawlId": crawl.ID,
+		})
+	}
+	return nil
+}
+
+// crawlPages returns the normalized URLs of the pages already part of a crawl.
+func crawlPages(crawlID string) (map[string]bool, error) {
+	var known []string
+	if err := config.DB.Model(&models.URL{}).Where("crawl_id = ?", crawlID).Pluck("url", &known).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(known))
+	for _, raw := range known {
+		if normalized, err := NormalizeURL(raw); err == nil {
+			seen[normalized] = true
+		}
+	}
+	return seen, nil
+}
+
+/*
+RefreshCrawlStats recomputes the aggregate counters of a crawl from its
+pages and marks the crawl as done once no page is queued or running.
+*/
+func RefreshCrawlStats(crawlID string) (*models.Crawl, error) {
+	var crawl models.Crawl
+	if err := config.DB.First(&crawl, "id = ?", crawlID).Error; err != nil {
+		return nil, err
+	}
+
+	var agg struct {
+		Total        int
+		Queued       int
+		Done         int
+		Failed       int
+		Internal     int
+		External     int
+		Inaccessible int
+		Login        int
+		Weight       int64
+		Mixed        int
+	}
+	err := config.DB.Model(&models.URL{}).
+		Where("crawl_id = ?", crawlID).
+		Select(`COUNT(*) AS total,
+			COALESCE(SUM(CASE WHEN status IN ('queued', 'running') THEN 1 ELSE 0 END), 0) AS queued,
+			COALESCE(SUM(CASE WHEN status = 'done' THEN 1 ELSE 0 END), 0) AS done,
+			COALESCE(SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END), 0) AS failed,
+			COALESCE(SUM(internal_links_count), 0) AS internal,
+			COALESCE(SUM(external_links_count), 0) AS external,
+			COALESCE(SUM(inaccessible_links_count), 0) AS inaccessible,
+			COALESCE(SUM(CASE WHEN has_login_form THEN 1 ELSE 0 END), 0) AS login,
+			COALESCE(SUM(page_weight_bytes), 0) AS weight,
+			COALESCE(SUM(CASE WHEN mixed_content_active + mixed_content_passive > 0 THEN 1 ELSE 0 END), 0) AS mixed`).
+		Scan(&agg).Error
+	if err != nil {
+		return nil, err
+	}
+
+	crawl.PagesTotal = agg.Total
+	crawl.PagesQueued = agg.Queued
+	crawl.PagesDone = agg.Done
+	crawl.PagesFailed = agg.Failed
+	crawl.InternalLinksCount = agg.Internal
+	crawl.ExternalLinksCount = agg.External
+	crawl.InaccessibleLinksCount = agg.Inaccessible
+	crawl.PagesWithLoginForm = agg.Login
+	crawl.PageWeightBytes = agg.Weight
+	crawl.MixedContentPages = agg.Mixed
+
+	if agg.Queued == 0 {
+		crawl.Status = "done"
+		if crawl.FinishedAt == nil {
+			now := time.Now()
+			crawl.FinishedAt = &now
+		}
+	} else {
+		crawl.Status = "running"
+		crawl.FinishedAt = nil
+	}
+
+	if err := config.DB.Save(&crawl).Error; err != nil {
+		return nil, err
+	}
+	return &crawl, nil
+}
+
+// compilePatterns compiles a list of user-supplied URL regexps.
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.New("invalid URL pattern " + p + ": " + err.Error())
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// crawlPatternsAllow reports whether rawURL passes the include and exclude patterns.
+func crawlPatternsAllow(rawURL string, include, exclude []*regexp.Regexp) bool {
+	for _, re := range exclude {
+		if re.MatchString(rawURL) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, re := range include {
+		if re.MatchString(rawURL) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,623 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"golang.org/x/net/html"
+)
+
+// Severities of CSPFinding, from most to least serious.
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+	SeverityInfo   = "info"
+)
+
+// Delivery mechanisms recorded in CSPPolicy.Delivery.
+const (
+	CSPDeliveryHeader     = "header"
+	CSPDeliveryReportOnly = "report-only"
+	CSPDeliveryMeta       = "meta"
+)
+
+// cspKnownDirectives are the directives defined by CSP Level 3 and its companion specs.
+var cspKnownDirectives = map[string]bool{
+	"default-src": true, "script-src": true, "script-src-elem": true, "script-src-attr": true,
+	"style-src": true, "style-src-elem": true, "style-src-attr": true, "img-src": true,
+	"font-src": true, "connect-src": true, "media-src": true, "object-src": true,
+	"frame-src": true, "child-src": true, "worker-src": true, "manifest-src": true,
+	"base-uri": true, "form-action": true, "frame-ancestors": true, "sandbox": true,
+	"report-uri": true, "report-to": true, "upgrade-insecure-requests": true,
+	"block-all-mixed-content": true, "require-trusted-types-for": true, "trusted-types": true,
+	"prefetch-src": true, "navigate-to": true, "plugin-types": true, "webrtc": true,
+}
+
+// cspMetaIgnored are directives browsers ignore when the policy is delivered in a <meta> tag.
+var cspMetaIgnored = []string{"frame-ancestors", "report-uri", "sandbox"}
+
+/*
+cspFallbacks gives, per resource type of the subresource inventory, the
+directives that govern it in order of precedence. Form targets are only
+governed by form-action, which does not fall back to default-src.
+*/
+var cspFallbacks = map[string][]string{
+	"script":     {"script-src-elem", "script-src", "default-src"},
+	"stylesheet": {"style-src-elem", "style-src", "default-src"},
+	"image":      {"img-src", "default-src"},
+	"icon":       {"img-src", "default-src"},
+	"font":       {"font-src", "default-src"},
+	"media":      {"media-src", "default-src"},
+	"iframe":     {"frame-src", "child-src", "default-src"},
+	"object":     {"object-src", "default-src"},
+	"manifest":   {"manifest-src", "default-src"},
+	"form":       {"form-action"},
+}
+
+// parseCSP splits a serialized policy into directives; repeated directives after the first are ignored.
+func parseCSP(delivery, raw string) models.CSPPolicy {
+	policy := models.CSPPolicy{Delivery: delivery, Raw: strings.TrimSpace(raw)}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(raw, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		policy.Directives = append(policy.Directives, models.CSPDirective{Name: name, Sources: fields[1:]})
+	}
+	return policy
+}
+
+/*
+collectCSPPolicies returns the policies of a page: each enforced
+Content-Security-Policy header (a header may carry several
+comma-separated policies), each report-only header and each
+<meta http-equiv="Content-Security-Policy"> tag.
+*/
+func collectCSPPolicies(header http.Header, doc *html.Node) []models.CSPPolicy {
+	policies := []models.CSPPolicy{}
+	for _, d := range []struct{ delivery, header string }{
+		{CSPDeliveryHeader, "Content-Security-Policy"},
+		{CSPDeliveryReportOnly, "Content-Security-Policy-Report-Only"},
+	} {
+		for _, value := range header.Values(d.header) {
+			for _, raw := range strings.Split(value, ",") {
+				if strings.TrimSpace(raw) != "" {
+					policies = append(policies, parseCSP(d.delivery, raw))
+				}
+			}
+		}
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && strings.EqualFold(n.Data, "meta") &&
+			strings.EqualFold(strings.TrimSpace(attrValue(n, "http-equiv")), "content-security-policy") {
+			if content := attrValue(n, "content"); strings.TrimSpace(content) != "" {
+				policies = append(policies, parseCSP(CSPDeliveryMeta, content))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return policies
+}
+
+// cspScript is a <script> element of the page as seen by the policy check.
+type cspScript struct {
+	src    string // Absolute URL of an external script, empty for inline ones
+	nonce  string
+	inline string // Inline script text
+}
+
+/*
+pageScripts collects the executable <script> elements of a page (data
+blocks such as JSON-LD are skipped) and counts inline event handler
+attributes and javascript: URLs, which only 'unsafe-inline' (or
+'unsafe-hashes') allows.
+*/
+func pageScripts(doc *html.Node, pageURL string) (scripts []cspScript, inlineHandlers int) {
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, attr := range n.Attr {
+				key := strings.ToLower(attr.Key)
+				if strings.HasPrefix(key, "on") && len(key) > 2 {
+					inlineHandlers++
+				} else if (key == "href" || key == "action" || key == "src") && hasSchemePrefix(strings.TrimSpace(attr.Val), "javascript:") {
+					inlineHandlers++
+				}
+			}
+			if strings.EqualFold(n.Data, "script") && isExecutableScript(attrValue(n, "type")) {
+				s := cspScript{nonce: attrValue(n, "nonce")}
+				if src := strings.TrimSpace(attrValue(n, "src")); src != "" {
+					s.src = resolveURL(pageURL, src)
+				} else {
+					s.inline = nodeRawText(n)
+				}
+				scripts = append(scripts, s)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return scripts, inlineHandlers
+}
+
+// isExecutableScript reports whether a script type attribute denotes JavaScript.
+func isExecutableScript(typ string) bool {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if i := strings.IndexByte(typ, ';'); i >= 0 {
+		typ = strings.TrimSpace(typ[:i])
+	}
+	switch typ {
+	case "", "module", "text/javascript", "application/javascript", "text/ecmascript", "application/ecmascript":
+		return true
+	}
+	return false
+}
+
+/*
+applyCSP parses the page's Content Security Policies, evaluates every
+enforced policy for weaknesses, cross-checks it against the page's
+scripts and subresource inventory, and stores policies and findings.
+*/
+func applyCSP(result *models.AnalysisResult, header http.Header, doc *html.Node, pageURL string, inventory []models.Resource) {
+	policies := collectCSPPolicies(header, doc)
+	scripts, handlers := pageScripts(doc, pageURL)
+	page, _ := url.Parse(pageURL)
+
+	findings := []models.CSPFinding{}
+	var enforced []models.CSPPolicy
+	for _, p := range policies {
+		if p.Delivery != CSPDeliveryReportOnly {
+			enforced = append(enforced, p)
+		}
+	}
+	reported := map[string]bool{}
+	for _, p := range enforced {
+		for _, f := range evaluateCSP(p) {
+			// Policies are enforced together: a directive missing from one may be
+			// set by another, and is reported once when missing from all
+			if _, missing := cspMissingDirectives[f.Code]; missing {
+				if reported[f.Code] || cspCoveredElsewhere(f.Code, enforced) {
+					continue
+				}
+				reported[f.Code] = true
+			}
+			findings = append(findings, f)
+		}
+		if page != nil {
+			findings = append(findings, crossCheckCSP(p, page, scripts, handlers, inventory)...)
+		}
+	}
+	switch {
+	case len(enforced) == 0 && len(policies) > 0:
+		findings = append(findings, models.CSPFinding{Severity: SeverityMedium, Code: "report-only",
+			Message: "The Content Security Policy is only delivered in report-only mode and is not enforced"})
+	case len(enforced) == 0:
+		findings = append(findings, models.CSPFinding{Severity: SeverityHigh, Code: "missing-policy",
+			Message: "No Content Security Policy is delivered"})
+	}
+
+	result.CSPPolicies = policies
+	result.CSPFindings = findings
+	result.CSPFindingCount = len(findings)
+	result.CSPHighCount = 0
+	for _, f := range findings {
+		if f.Severity == SeverityHigh {
+			result.CSPHighCount++
+		}
+	}
+}
+
+// cspMissingDirectives maps findings about absent directives to the directives that resolve them.
+var cspMissingDirectives = map[string][]string{
+	"no-script-restriction":   {"script-src", "default-src"},
+	"missing-object-src":      {"object-src", "default-src"},
+	"missing-base-uri":        {"base-uri"},
+	"missing-frame-ancestors": {"frame-ancestors"},
+}
+
+/*
+cspCoveredElsewhere reports whether a finding about an absent directive
+is resolved by another enforced policy that sets it (frame-ancestors only
+counts when delivered in a header).
+*/
+func cspCoveredElsewhere(code string, enforced []models.CSPPolicy) bool {
+	names, ok := cspMissingDirectives[code]
+	if !ok {
+		return false
+	}
+	for _, p := range enforced {
+		if code == "missing-frame-ancestors" && p.Delivery == CSPDeliveryMeta {
+			continue
+		}
+		if _, _, ok := cspDirective(p, names...); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// cspDirective returns the sources of the first of names present in p.
+func cspDirective(p models.CSPPolicy, names ...string) (string, []string, bool) {
+	for _, name := range names {
+		for _, d := range p.Directives {
+			if d.Name == name {
+				return d.Name, d.Sources, true
+			}
+		}
+	}
+	return "", nil, false
+}
+
+// hasCSPKeyword reports whether sources contains a keyword such as 'unsafe-inline'.
+func hasCSPKeyword(sources []string, keyword string) bool {
+	for _, s := range sources {
+		if strings.EqualFold(s, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNonceOrHash reports whether sources allow inline scripts by nonce or hash.
+func hasNonceOrHash(sources []string) bool {
+	for _, s := range sources {
+		l := strings.ToLower(s)
+		if strings.HasPrefix(l, "'nonce-") || strings.HasPrefix(l, "'sha256-") ||
+			strings.HasPrefix(l, "'sha384-") || strings.HasPrefix(l, "'sha512-") {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateCSP checks a single enforced policy for weaknesses.
+func evaluateCSP(p models.CSPPolicy) []models.CSPFinding {
+	var findings []models.CSPFinding
+	add := func(severity, code, directive, format string, args ...interface{}) {
+		findings = append(findings, models.CSPFinding{
+			Severity: severity, Code: code, Directive: directive, Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, d := range p.Directives {
+		if !cspKnownDirectives[d.Name] {
+			add(SeverityLow, "unknown-directive", d.Name, "Unknown directive %q is ignored by browsers", d.Name)
+		}
+	}
+	if p.Delivery == CSPDeliveryMeta {
+		for _, name := range cspMetaIgnored {
+			if _, _, ok := cspDirective(p, name); ok {
+				add(SeverityInfo, "ignored-in-meta", name, "%s is ignored when the policy is delivered in a <meta> tag", name)
+			}
+		}
+	}
+
+	// Scripts
+	scriptDirective, scriptSources, ok := cspDirective(p, "script-src", "default-src")
+	if !ok {
+		add(SeverityHigh, "no-script-restriction", "script-src", "Neither script-src nor default-src is set, so any script may run")
+	} else {
+		strictDynamic := hasCSPKeyword(scriptSources, "'strict-dynamic'")
+		nonceOrHash := hasNonceOrHash(scriptSources)
+		if hasCSPKeyword(scriptSources, "'unsafe-inline'") {
+			if nonceOrHash {
+				add(SeverityInfo, "unsafe-inline", scriptDirective, "'unsafe-inline' is ignored by modern browsers because a nonce or hash is present")
+			} else {
+				add(SeverityHigh, "unsafe-inline", scriptDirective, "'unsafe-inline' allows injected inline scripts and event handlers to run")
+			}
+		}
+		if hasCSPKeyword(scriptSources, "'unsafe-eval'") {
+			add(SeverityMedium, "unsafe-eval", scriptDirective, "'unsafe-eval' allows eval() and similar string-to-code APIs")
+		}
+		if !strictDynamic {
+			for _, s := range scriptSources {
+				switch strings.ToLower(s) {
+				case "*", "http:", "https:":
+					add(SeverityHigh, "wildcard-source", scriptDirective, "%s allows scripts from any host", s)
+				case "data:", "blob:":
+					add(SeverityHigh, "unsafe-scheme", scriptDirective, "%s allows scripts from attacker-controlled URLs", s)
+				default:
+					if strings.HasPrefix(strings.ToLower(s), "http://") {
+						add(SeverityMedium, "insecure-source", scriptDirective, "%s loads scripts over plain HTTP", s)
+					} else if strings.Contains(s, "*") {
+						add(SeverityLow, "wildcard-host", scriptDirective, "%s allows scripts from every matching subdomain", s)
+					}
+				}
+			}
+		}
+	}
+
+	// Plugins and <base>
+	objectDirective, objectSources, ok := cspDirective(p, "object-src", "default-src")
+	switch {
+	case !ok:
+		add(SeverityHigh, "missing-object-src", "object-src", "object-src is missing and no default-src applies, so plugins may load from anywhere; set object-src 'none'")
+	case !(len(objectSources) == 1 && strings.EqualFold(objectSources[0], "'none'")):
+		add(SeverityMedium, "object-src-not-none", objectDirective, "Plugins are allowed by %s; set object-src 'none'", objectDirective)
+	}
+	if _, _, ok := cspDirective(p, "base-uri"); !ok {
+		severity := SeverityMedium
+		if scriptSources != nil && hasNonceOrHash(scriptSources) {
+			severity = SeverityHigh
+		}
+		add(severity, "missing-base-uri", "base-uri", "base-uri is missing, so an injected <base> tag can redirect relative script URLs; set base-uri 'none' or 'self'")
+	}
+
+	// Wildcards in the remaining fetch directives
+	for _, d := range p.Directives {
+		if d.Name == "script-src" || d.Name == "object-src" || (d.Name == "default-src" && d.Name == scriptDirective) {
+			continue
+		}
+		if hasCSPKeyword(d.Sources, "*") {
+			severity := SeverityLow
+			if d.Name == "default-src" || d.Name == "frame-src" || d.Name == "child-src" {
+				severity = SeverityMedium
+			}
+			add(severity, "wildcard-source", d.Name, "* allows %s content from any host", d.Name)
+		}
+	}
+	if p.Delivery != CSPDeliveryMeta {
+		if _, _, ok := cspDirective(p, "frame-ancestors"); !ok {
+			add(SeverityLow, "missing-frame-ancestors", "frame-ancestors", "frame-ancestors is missing, so the page may be framed by any site")
+		}
+	}
+	return findings
+}
+
+/*
+crossCheckCSP compares an enforced policy with what the page contains:
+inline scripts and handlers it would block, and external scripts and
+subresources not allowed by the directive governing them.
+*/
+func crossCheckCSP(p models.CSPPolicy, page *url.URL, scripts []cspScript, handlers int, inventory []models.Resource) []models.CSPFinding {
+	var findings []models.CSPFinding
+	add := func(severity, code, directive, format string, args ...interface{}) {
+		findings = append(findings, models.CSPFinding{
+			Severity: severity, Code: code, Directive: directive, Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	scriptDirective, scriptSources, restricted := cspDirective(p, "script-src-elem", "script-src", "default-src")
+	if restricted {
+		nonceOrHash := hasNonceOrHash(scriptSources)
+		inlineAllowed := hasCSPKeyword(scriptSources, "'unsafe-inline'") && !nonceOrHash
+		strictDynamic := hasCSPKeyword(scriptSources, "'strict-dynamic'")
+
+		blockedInline, blockedExternal := 0, 0
+		example := ""
+		for _, s := range scripts {
+			if cspNonceMatches(scriptSources, s.nonce) {
+				continue
+			}
+			if s.src == "" {
+				if !inlineAllowed && !cspHashMatches(scriptSources, s.inline) {
+					blockedInline++
+				}
+				continue
+			}
+			target, err := url.Parse(s.src)
+			if err != nil {
+				continue
+			}
+			if strictDynamic || !cspAllows(scriptSources, target, page) {
+				blockedExternal++
+				if example == "" {
+					example = s.src
+				}
+			}
+		}
+		if blockedInline > 0 {
+			add(SeverityMedium, "blocked-inline-script", scriptDirective,
+				"%d inline script(s) on the page have no matching nonce or hash and are blocked by %s", blockedInline, scriptDirective)
+		}
+		if blockedExternal > 0 {
+			add(SeverityMedium, "blocked-source", scriptDirective,
+				"%d external script(s) are not allowed by %s, e.g. %s", blockedExternal, scriptDirective, example)
+		}
+
+		_, attrSources, _ := cspDirective(p, "script-src-attr", "script-src", "default-src")
+		if handlers > 0 && !(hasCSPKeyword(attrSources, "'unsafe-inline'") && !hasNonceOrHash(attrSources)) &&
+			!hasCSPKeyword(attrSources, "'unsafe-hashes'") {
+			add(SeverityMedium, "blocked-inline-handler", scriptDirective,
+				"%d inline event handler(s) or javascript: URL(s) on the page are blocked", handlers)
+		}
+	}
+
+	// Other subresources, grouped by the directive that governs them
+	type blocked struct {
+		count   int
+		example string
+	}
+	byDirective := map[string]*blocked{}
+	var order []string
+	for _, r := range inventory {
+		if r.Type == "script" {
+			continue
+		}
+		directive, sources, ok := cspDirective(p, cspFallbacks[r.Type]...)
+		if !ok {
+			continue
+		}
+		target, err := url.Parse(r.URL)
+		if err != nil || cspAllows(sources, target, page) {
+			continue
+		}
+		b, ok := byDirective[directive]
+		if !ok {
+			b = &blocked{example: r.URL}
+			byDirective[directive] = b
+			order = append(order, directive)
+		}
+		b.count++
+	}
+	for _, directive := range order {
+		b := byDirective[directive]
+		add(SeverityMedium, "blocked-source", directive,
+			"%d resource(s) on the page are not allowed by %s, e.g. %s", b.count, directive, b.example)
+	}
+	return findings
+}
+
+// cspNonceMatches reports whether sources contain 'nonce-<nonce>'.
+func cspNonceMatches(sources []string, nonce string) bool {
+	if nonce == "" {
+		return false
+	}
+	return hasCSPKeyword(sources, "'nonce-"+nonce+"'")
+}
+
+// cspHashMatches reports whether sources contain a hash of the inline script text.
+func cspHashMatches(sources []string, text string) bool {
+	for _, s := range sources {
+		l := strings.ToLower(s)
+		var h hash.Hash
+		switch {
+		case strings.HasPrefix(l, "'sha256-"):
+			h = sha256.New()
+		case strings.HasPrefix(l, "'sha384-"):
+			h = sha512.New384()
+		case strings.HasPrefix(l, "'sha512-"):
+			h = sha512.New()
+		default:
+			continue
+		}
+		h.Write([]byte(text))
+		want := strings.TrimSuffix(s[len("'sha256-"):], "'")
+		if base64.StdEncoding.EncodeToString(h.Sum(nil)) == want {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+cspAllows reports whether a source list allows loading target from page,
+following the CSP Level 3 matching rules for '*', 'self', scheme sources
+and host sources (with wildcard subdomains, ports and paths). HTTP
+sources also match their HTTPS upgrade.
+*/
+func cspAllows(sources []string, target, page *url.URL) bool {
+	scheme := strings.ToLower(target.Scheme)
+	for _, s := range sources {
+		l := strings.ToLower(s)
+		switch {
+		case l == "'none'":
+			continue
+		case l == "*":
+			if scheme != "data" && scheme != "blob" && scheme != "filesystem" {
+				return true
+			}
+		case l == "'self'":
+			if strings.EqualFold(target.Hostname(), page.Hostname()) && effectivePort(target) == effectivePort(page) &&
+				schemeMatches(strings.ToLower(page.Scheme), scheme) {
+				return true
+			}
+			if strings.EqualFold(target.Host, page.Host) && page.Scheme == "http" && scheme == "https" {
+				return true
+			}
+		case strings.HasPrefix(l, "'"):
+			continue // nonces, hashes and other keywords
+		case strings.HasSuffix(l, ":") && !strings.Contains(l, "/"):
+			if schemeMatches(strings.TrimSuffix(l, ":"), scheme) {
+				return true
+			}
+		default:
+			if hostSourceMatches(l, target, page) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// schemeMatches reports whether a resource scheme satisfies an allowed one (http also allows https, ws wss).
+func schemeMatches(allowed, scheme string) bool {
+	return allowed == scheme || (allowed == "http" && scheme == "https") || (allowed == "ws" && scheme == "wss")
+}
+
+// effectivePort returns the port of u, or the default port of its scheme.
+func effectivePort(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	}
+	return ""
+}
+
+// hostSourceMatches matches a lowercased host-source expression against target.
+func hostSourceMatches(source string, target, page *url.URL) bool {
+	scheme := strings.ToLower(target.Scheme)
+	rest := source
+	if i := strings.Index(rest, "://"); i >= 0 {
+		if !schemeMatches(rest[:i], scheme) {
+			return false
+		}
+		rest = rest[i+3:]
+	} else if !schemeMatches(strings.ToLower(page.Scheme), scheme) {
+		return false
+	}
+
+	path := ""
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		rest, path = rest[:i], rest[i:]
+	}
+	host, port := rest, ""
+	if i := strings.LastIndexByte(rest, ':'); i >= 0 {
+		host, port = rest[:i], rest[i+1:]
+	}
+
+	targetHost := strings.ToLower(target.Hostname())
+	if strings.HasPrefix(host, "*.") {
+		if !strings.HasSuffix(targetHost, host[1:]) {
+			return false
+		}
+	} else if host != targetHost {
+		return false
+	}
+
+	switch {
+	case port == "*":
+	case port != "":
+		if port != effectivePort(target) && !(port == "80" && effectivePort(target) == "443") {
+			return false
+		}
+	default:
+		if target.Port() != "" && target.Port() != effectivePort(&url.URL{Scheme: scheme}) {
+			return false
+		}
+	}
+
+	if path != "" {
+		if strings.HasSuffix(path, "/") {
+			return strings.HasPrefix(target.Path, path)
+		}
+		return target.Path == path
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"net/url"
+	"testing"
+)
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", raw, err)
+	}
+	return u
+}
+
+func TestCSPAllows(t *testing.T) {
+	const page = "https://example.com/index.html"
+	tests := []struct {
+		name    string
+		sources []string
+		target  string
+		want    bool
+	}{
+		{"none", []string{"'none'"}, "https://example.com/a.js", false},
+		{"empty list", nil, "https://example.com/a.js", false},
+		{"wildcard", []string{"*"}, "https://cdn.other.org/a.js", true},
+		{"wildcard excludes data", []string{"*"}, "data:text/javascript,alert(1)", false},
+		{"wildcard excludes blob", []string{"*"}, "blob:https://example.com/uuid", false},
+		{"self same origin", []string{"'self'"}, "https://example.com/a.js", true},
+		{"self is case-insensitive", []string{"'SELF'"}, "https://EXAMPLE.com/a.js", true},
+		{"self other host", []string{"'self'"}, "https://cdn.example.com/a.js", false},
+		{"self other port", []string{"'self'"}, "https://example.com:8443/a.js", false},
+		{"self downgrade", []string{"'self'"}, "http://example.com/a.js", false},
+		{"scheme source", []string{"https:"}, "https://cdn.other.org/a.js", true},
+		{"scheme source mismatch", []string{"https:"}, "http://cdn.other.org/a.js", false},
+		{"data scheme source", []string{"data:"}, "data:image/png;base64,AAAA", true},
+		{"http scheme allows https", []string{"http:"}, "https://cdn.other.org/a.js", true},
+		{"host source", []string{"cdn.other.org"}, "https://cdn.other.org/a.js", true},
+		{"host source mismatch", []string{"cdn.other.org"}, "https://evil.org/a.js", false},
+		{"keywords are skipped", []string{"'unsafe-inline'", "'nonce-abc'"}, "https://example.com/a.js", false},
+		{"any source matches", []string{"'none'", "cdn.other.org", "'self'"}, "https://example.com/a.js", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cspAllows(tt.sources, mustParseURL(t, tt.target), mustParseURL(t, page))
+			if got != tt.want {
+				t.Errorf("cspAllows(%q, %q) = %v, want %v", tt.sources, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSPAllowsSelfFromHTTPPage(t *testing.T) {
+	page := mustParseURL(t, "http://example.com/")
+	if !cspAllows([]string{"'self'"}, mustParseURL(t, "https://example.com/a.js"), page) {
+		t.Error("'self' on an http page should allow the https upgrade of the same host")
+	}
+}
+
+func TestHostSourceMatches(t *testing.T) {
+	const page = "https://example.com/"
+	tests := []struct {
+		name   string
+		source string
+		target string
+		want   bool
+	}{
+		{"exact host", "cdn.example.com", "https://cdn.example.com/a.js", true},
+		{"other host", "cdn.example.com", "https://img.example.com/a.js", false},
+		{"wildcard subdomain", "*.example.com", "https://a.b.example.com/a.js", true},
+		{"wildcard excludes apex", "*.example.com", "https://example.com/a.js", false},
+		{"wildcard needs dot boundary", "*.example.com", "https://badexample.com/a.js", false},
+		{"explicit scheme", "https://cdn.example.com", "https://cdn.example.com/a.js", true},
+		{"explicit scheme mismatch", "https://cdn.example.com", "http://cdn.example.com/a.js", false},
+		{"http scheme allows https", "http://cdn.example.com", "https://cdn.example.com/a.js", true},
+		{"page scheme applies without one", "cdn.example.com", "http://cdn.example.com/a.js", false},
+		{"default port", "cdn.example.com", "https://cdn.example.com:443/a.js", true},
+		{"non-default port without port", "cdn.example.com", "https://cdn.example.com:8443/a.js", false},
+		{"explicit port", "cdn.example.com:8443", "https://cdn.example.com:8443/a.js", true},
+		{"explicit port mismatch", "cdn.example.com:8443", "https://cdn.example.com/a.js", false},
+		{"port 80 allows 443 upgrade", "http://cdn.example.com:80", "https://cdn.example.com/a.js", true},
+		{"wildcard port", "cdn.example.com:*", "https://cdn.example.com:9000/a.js", true},
+		{"directory path", "cdn.example.com/js/", "https://cdn.example.com/js/app.js", true},
+		{"directory path mismatch", "cdn.example.com/js/", "https://cdn.example.com/css/app.css", false},
+		{"exact path", "cdn.example.com/js/app.js", "https://cdn.example.com/js/app.js", true},
+		{"exact path mismatch", "cdn.example.com/js/app.js", "https://cdn.example.com/js/app.js.map", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hostSourceMatches(tt.source, mustParseURL(t, tt.target), mustParseURL(t, page))
+			if got != tt.want {
+				t.Errorf("hostSourceMatches(%q, %q) = %v, want %v", tt.source, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSPHashMatches(t *testing.T) {
+	const script = "alert('hello')"
+	sum256 := sha256.Sum256([]byte(script))
+	sum384 := sha512.Sum384([]byte(script))
+	sum512 := sha512.Sum512([]byte(script))
+	hash256 := base64.StdEncoding.EncodeToString(sum256[:])
+	hash384 := base64.StdEncoding.EncodeToString(sum384[:])
+	hash512 := base64.StdEncoding.EncodeToString(sum512[:])
+
+	tests := []struct {
+		name    string
+		sources []string
+		text    string
+		want    bool
+	}{
+		{"sha256", []string{"'sha256-" + hash256 + "'"}, script, true},
+		{"sha384", []string{"'sha384-" + hash384 + "'"}, script, true},
+		{"sha512", []string{"'sha512-" + hash512 + "'"}, script, true},
+		{"algorithm is case-insensitive", []string{"'SHA256-" + hash256 + "'"}, script, true},
+		{"among other sources", []string{"'self'", "'nonce-abc'", "'sha256-" + hash256 + "'"}, script, true},
+		{"different text", []string{"'sha256-" + hash256 + "'"}, script + " ", false},
+		{"wrong algorithm", []string{"'sha384-" + hash256 + "'"}, script, false},
+		{"unsupported algorithm", []string{"'sha1-" + hash256 + "'"}, script, false},
+		{"no hash sources", []string{"'self'", "https:"}, script, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cspHashMatches(tt.sources, tt.text); got != tt.want {
+				t.Errorf("cspHashMatches(%q, %q) = %v, want %v", tt.sources, tt.text, got, tt.want)
+			}
+		})
+	}
+}
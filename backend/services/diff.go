@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"gorm.io/gorm"
+)
+
+// ErrNoPreviousRun is returned when a run has no earlier successful run to be compared with.
+var ErrNoPreviousRun = errors.New("no earlier successful run to compare with")
+
+/*
+RecordRunDiff compares a completed run with the previous successful run
+of the same URL and stores the result, replacing any diff stored earlier
+for the run.
+
+Failed runs are not compared. Returns ErrNoPreviousRun for the first
+successful run of a URL.
+*/
+func RecordRunDiff(run *models.AnalysisRun) (*models.RunDiff, error) {
+	if run.Status != "done" {
+		return nil, errors.New("run did not complete successfully")
+	}
+
+	var prev models.AnalysisRun
+	err := config.DB.
+		Where("url_id = ? AND status = ? AND started_at < ?", run.URLID, "done", run.StartedAt).
+		Order("started_at desc").
+		First(&prev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoPreviousRun
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	diff := DiffRuns(&prev, run)
+
+	var existing models.RunDiff
+	if err := config.DB.Select("id").Where("run_id = ?", run.ID).First(&existing).Error; err == nil {
+		diff.ID = existing.ID
+	}
+	if err := config.DB.Save(&diff).Error; err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+/*
+GetRunDiff returns the stored diff of a run, computing and storing it
+first if the run predates change detection or its diff is missing.
+*/
+func GetRunDiff(run *models.AnalysisRun) (*models.RunDiff, error) {
+	var diff models.RunDiff
+	err := config.DB.Where("run_id = ?", run.ID).First(&diff).Error
+	if err == nil {
+		return &diff, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return RecordRunDiff(run)
+}
+
+/*
+DiffRuns compares the results of two runs of the same URL: title,
+headings, link targets, newly broken links, meta tags and canonical URL,
+and the HTML content hash.
+*/
+func DiffRuns(prev, cur *models.AnalysisRun) models.RunDiff {
+	d := models.RunDiff{
+		URLID:           cur.URLID,
+		RunID:           cur.ID,
+		PreviousRunID:   prev.ID,
+		HeadingsAdded:   []models.Heading{},
+		HeadingsRemoved: []models.Heading{},
+		MetadataChanges: []models.FieldChange{},
+	}
+
+	if prev.PageTitle != cur.PageTitle {
+		d.TitleChanged = true
+		d.PreviousTitle = prev.PageTitle
+		d.Title = cur.PageTitle
+	}
+
+	// Headings are compared as multisets of (level, text)
+	key := func(h models.Heading) string { return strconv.Itoa(h.Level) + "\x00" + h.Text }
+	remaining := map[string]int{}
+	for _, h := range prev.Headings {
+		remaining[key(h)]++
+	}
+	for _, h := range cur.Headings {
+		if remaining[key(h)] > 0 {
+			remaining[key(h)]--
+			continue
+		}
+		d.HeadingsAdded = append(d.HeadingsAdded, h)
+	}
+	for _, h := range prev.Headings {
+		if remaining[key(h)] > 0 {
+			remaining[key(h)]--
+			d.HeadingsRemoved = append(d.HeadingsRemoved, h)
+		}
+	}
+
+	// Links are compared by target URL
+	prevLinks := map[string]models.Link{}
+	for _, l := range prev.Links {
+		prevLinks[l.URL] = l
+	}
+	curLinks := map[string]bool{}
+	d.LinksAdded = []string{}
+	d.NewBrokenLinks = []string{}
+	for _, l := range cur.Links {
+		curLinks[l.URL] = true
+		old, existed := prevLinks[l.URL]
+		if !existed {
+			d.LinksAdded = append(d.LinksAdded, l.URL)
+		}
+		if l.Inaccessible() && !(existed && old.Inaccessible()) {
+			d.NewBrokenLinks = append(d.NewBrokenLinks, l.URL)
+		}
+	}
+	d.LinksRemoved = []string{}
+	for _, l := range prev.Links {
+		if !curLinks[l.URL] {
+			d.LinksRemoved = append(d.LinksRemoved, l.URL)
+		}
+	}
+
+	// Metadata: canonical URL plus every named meta tag
+	if prev.CanonicalURL != cur.CanonicalURL {
+		d.MetadataChanges = append(d.MetadataChanges, models.FieldChange{
+			Field: "canonical", Previous: prev.CanonicalURL, Current: cur.CanonicalURL,
+		})
+	}
+	prevMeta, curMeta := metaValues(prev.Meta), metaValues(cur.Meta)
+	names := make([]string, 0, len(prevMeta)+len(curMeta))
+	for name := range prevMeta {
+		names = append(names, name)
+	}
+	for name := range curMeta {
+		if _, ok := prevMeta[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if prevMeta[name] != curMeta[name] {
+			d.MetadataChanges = append(d.MetadataChanges, models.FieldChange{
+				Field: "meta:" + name, Previous: prevMeta[name], Current: curMeta[name],
+			})
+		}
+	}
+
+	if prev.ContentHash != cur.ContentHash {
+		d.ContentHashChanged = true
+		d.PreviousContentHash = prev.ContentHash
+		d.ContentHash = cur.ContentHash
+	}
+
+	d.Changed = d.TitleChanged || d.ContentHashChanged ||
+		len(d.HeadingsAdded) > 0 || len(d.HeadingsRemoved) > 0 ||
+		len(d.LinksAdded) > 0 || len(d.LinksRemoved) > 0 ||
+		len(d.NewBrokenLinks) > 0 || len(d.MetadataChanges) > 0
+	return d
+}
+
+// metaValues maps meta tag names to their content; repeated names are joined in order.
+func metaValues(tags []models.MetaTag) map[string]string {
+	values := map[string]string{}
+	for _, t := range tags {
+		if v, ok := values[t.Name]; ok {
+			values[t.Name] = v + "\n" + t.Content
+		} else {
+			values[t.Name] = t.Content
+		}
+	}
+	return values
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DMequanint/url-analyzer-pro/models"
+)
+
+// runWith returns a completed run with the given headings and links.
+func runWith(id string, headings []models.Heading, links []models.Link) *models.AnalysisRun {
+	run := &models.AnalysisRun{ID: id, URLID: "url", Status: "done"}
+	run.Headings = headings
+	run.Links = links
+	return run
+}
+
+func TestDiffRunsHeadings(t *testing.T) {
+	h := func(level int, text string) models.Heading { return models.Heading{Level: level, Text: text} }
+	tests := []struct {
+		name        string
+		prev, cur   []models.Heading
+		wantAdded   []models.Heading
+		wantRemoved []models.Heading
+	}{
+		{
+			name: "unchanged",
+			prev: []models.Heading{h(1, "Title"), h(2, "Intro")},
+			cur:  []models.Heading{h(1, "Title"), h(2, "Intro")},
+		},
+		{
+			name: "reordering is not a change",
+			prev: []models.Heading{h(2, "A"), h(2, "B")},
+			cur:  []models.Heading{h(2, "B"), h(2, "A")},
+		},
+		{
+			name:      "added",
+			prev:      []models.Heading{h(1, "Title")},
+			cur:       []models.Heading{h(1, "Title"), h(2, "News")},
+			wantAdded: []models.Heading{h(2, "News")},
+		},
+		{
+			name:        "removed",
+			prev:        []models.Heading{h(1, "Title"), h(2, "News")},
+			cur:         []models.Heading{h(1, "Title")},
+			wantRemoved: []models.Heading{h(2, "News")},
+		},
+		{
+			name:        "level change counts as remove and add",
+			prev:        []models.Heading{h(2, "Pricing")},
+			cur:         []models.Heading{h(3, "Pricing")},
+			wantAdded:   []models.Heading{h(3, "Pricing")},
+			wantRemoved: []models.Heading{h(2, "Pricing")},
+		},
+		{
+			name:      "duplicate added",
+			prev:      []models.Heading{h(2, "FAQ")},
+			cur:       []models.Heading{h(2, "FAQ"), h(2, "FAQ")},
+			wantAdded: []models.Heading{h(2, "FAQ")},
+		},
+		{
+			name:        "one of two duplicates removed",
+			prev:        []models.Heading{h(2, "FAQ"), h(1, "Title"), h(2, "FAQ")},
+			cur:         []models.Heading{h(1, "Title"), h(2, "FAQ")},
+			wantRemoved: []models.Heading{h(2, "FAQ")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DiffRuns(runWith("prev", tt.prev, nil), runWith("cur", tt.cur, nil))
+			if tt.wantAdded == nil {
+				tt.wantAdded = []models.Heading{}
+			}
+			if tt.wantRemoved == nil {
+				tt.wantRemoved = []models.Heading{}
+			}
+			if !reflect.DeepEqual([]models.Heading(d.HeadingsAdded), tt.wantAdded) {
+				t.Errorf("HeadingsAdded = %v, want %v", d.HeadingsAdded, tt.wantAdded)
+			}
+			if !reflect.DeepEqual([]models.Heading(d.HeadingsRemoved), tt.wantRemoved) {
+				t.Errorf("HeadingsRemoved = %v, want %v", d.HeadingsRemoved, tt.wantRemoved)
+			}
+			wantChanged := len(tt.wantAdded) > 0 || len(tt.wantRemoved) > 0
+			if d.Changed != wantChanged {
+				t.Errorf("Changed = %v, want %v", d.Changed, wantChanged)
+			}
+		})
+	}
+}
+
+func TestDiffRunsLinks(t *testing.T) {
+	ok := func(u string) models.Link { return models.Link{URL: u, Checked: true, StatusCode: 200} }
+	broken := func(u string) models.Link { return models.Link{URL: u, Checked: true, StatusCode: 404} }
+	failed := func(u string) models.Link { return models.Link{URL: u, Checked: true, Error: "connection refused"} }
+	unchecked := func(u string) models.Link { return models.Link{URL: u} }
+
+	tests := []struct {
+		name        string
+		prev, cur   []models.Link
+		wantAdded   []string
+		wantRemoved []string
+		wantBroken  []string
+	}{
+		{
+			name: "unchanged",
+			prev: []models.Link{ok("https://a.example/"), unchecked("https://b.example/")},
+			cur:  []models.Link{ok("https://a.example/"), unchecked("https://b.example/")},
+		},
+		{
+			name:        "added and removed",
+			prev:        []models.Link{ok("https://a.example/"), ok("https://old.example/")},
+			cur:         []models.Link{ok("https://a.example/"), ok("https://new.example/")},
+			wantAdded:   []string{"https://new.example/"},
+			wantRemoved: []string{"https://old.example/"},
+		},
+		{
+			name:       "link broke",
+			prev:       []models.Link{ok("https://a.example/")},
+			cur:        []models.Link{broken("https://a.example/")},
+			wantBroken: []string{"https://a.example/"},
+		},
+		{
+			name: "already broken is not new",
+			prev: []models.Link{broken("https://a.example/")},
+			cur:  []models.Link{failed("https://a.example/")},
+		},
+		{
+			name:       "previously unchecked link found broken",
+			prev:       []models.Link{unchecked("https://a.example/")},
+			cur:        []models.Link{broken("https://a.example/")},
+			wantBroken: []string{"https://a.example/"},
+		},
+		{
+			name:       "new link that is broken",
+			cur:        []models.Link{failed("https://down.example/")},
+			wantAdded:  []string{"https://down.example/"},
+			wantBroken: []string{"https://down.example/"},
+		},
+		{
+			name: "repaired link",
+			prev: []models.Link{broken("https://a.example/")},
+			cur:  []models.Link{ok("https://a.example/")},
+		},
+	}
+	orEmpty := func(s []string) []string {
+		if s == nil {
+			return []string{}
+		}
+		return s
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DiffRuns(runWith("prev", nil, tt.prev), runWith("cur", nil, tt.cur))
+			if got := []string(d.LinksAdded); !reflect.DeepEqual(got, orEmpty(tt.wantAdded)) {
+				t.Errorf("LinksAdded = %q, want %q", got, tt.wantAdded)
+			}
+			if got := []string(d.LinksRemoved); !reflect.DeepEqual(got, orEmpty(tt.wantRemoved)) {
+				t.Errorf("LinksRemoved = %q, want %q", got, tt.wantRemoved)
+			}
+			if got := []string(d.NewBrokenLinks); !reflect.DeepEqual(got, orEmpty(tt.wantBroken)) {
+				t.Errorf("NewBrokenLinks = %q, want %q", got, tt.wantBroken)
+			}
+			wantChanged := len(tt.wantAdded) > 0 || len(tt.wantRemoved) > 0 || len(tt.wantBroken) > 0
+			if d.Changed != wantChanged {
+				t.Errorf("Changed = %v, want %v", d.Changed, wantChanged)
+			}
+		})
+	}
+}
+
+func TestDiffRunsMetadata(t *testing.T) {
+	prev := runWith("prev", nil, nil)
+	prev.PageTitle = "Old"
+	prev.CanonicalURL = "https://example.com/"
+	prev.Meta = []models.MetaTag{{Name: "description", Content: "Old text"}, {Name: "robots", Content: "index"}}
+	prev.ContentHash = "aaa"
+
+	cur := runWith("cur", nil, nil)
+	cur.PageTitle = "New"
+	cur.CanonicalURL = "https://example.com/"
+	cur.Meta = []models.MetaTag{{Name: "description", Content: "New text"}, {Name: "og:title", Content: "New"}}
+	cur.ContentHash = "bbb"
+
+	d := DiffRuns(prev, cur)
+	if !d.TitleChanged || d.PreviousTitle != "Old" || d.Title != "New" {
+		t.Errorf("title change = %v %q -> %q, want Old -> New", d.TitleChanged, d.PreviousTitle, d.Title)
+	}
+	if !d.ContentHashChanged || d.PreviousContentHash != "aaa" || d.ContentHash != "bbb" {
+		t.Errorf("content hash change = %v %q -> %q, want aaa -> bbb", d.ContentHashChanged, d.PreviousContentHash, d.ContentHash)
+	}
+	want := []models.FieldChange{
+		{Field: "meta:description", Previous: "Old text", Current: "New text"},
+		{Field: "meta:og:title", Previous: "", Current: "New"},
+		{Field: "meta:robots", Previous: "index", Current: ""},
+	}
+	if got := []models.FieldChange(d.MetadataChanges); !reflect.DeepEqual(got, want) {
+		t.Errorf("MetadataChanges = %+v, want %+v", got, want)
+	}
+	if d.RunID != "cur" || d.PreviousRunID != "prev" || !d.Changed {
+		t.Errorf("diff ids = %q/%q, changed %v", d.RunID, d.PreviousRunID, d.Changed)
+	}
+}
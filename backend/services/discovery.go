@@ -0,0 +1,357 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// Kinds of discovered assets recorded in DiscoveredAsset.Kind.
+const (
+	AssetFeed           = "feed"
+	AssetManifest       = "manifest"
+	AssetIcon           = "icon"
+	AssetAppleTouchIcon = "apple-touch-icon"
+	AssetOpenSearch     = "opensearch"
+)
+
+// feedTypes maps the type attribute of a rel="alternate" link to the feed format it announces.
+var feedTypes = map[string]string{
+	"application/rss+xml":   "rss",
+	"application/atom+xml":  "atom",
+	"application/rdf+xml":   "rdf",
+	"application/feed+json": "json", // Plain application/json alternates are API endpoints (e.g. /wp-json), not feeds
+}
+
+/*
+discoveryRef returns the asset declared by a <link> element, if it
+announces a feed, web app manifest, icon or OpenSearch descriptor.
+*/
+func discoveryRef(n *html.Node, pageURL string) (models.DiscoveredAsset, bool) {
+	href := strings.TrimSpace(attrValue(n, "href"))
+	if href == "" {
+		return models.DiscoveredAsset{}, false
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(attrValue(n, "type")))
+	if mt, _, err := mime.ParseMediaType(mediaType); err == nil {
+		mediaType = mt
+	}
+
+	asset := models.DiscoveredAsset{
+		URL:   resolveURL(pageURL, href),
+		Type:  mediaType,
+		Title: strings.TrimSpace(attrValue(n, "title")),
+		Sizes: strings.TrimSpace(attrValue(n, "sizes")),
+	}
+	switch {
+	case hasRel(n, "alternate") && feedTypes[mediaType] != "":
+		asset.Kind = AssetFeed
+		asset.Format = feedTypes[mediaType]
+	case hasRel(n, "manifest"):
+		asset.Kind = AssetManifest
+	case hasRel(n, "apple-touch-icon"), hasRel(n, "apple-touch-icon-precomposed"):
+		asset.Kind = AssetAppleTouchIcon
+	case hasRel(n, "icon"):
+		asset.Kind = AssetIcon
+	case hasRel(n, "search") && mediaType == "application/opensearchdescription+xml":
+		asset.Kind = AssetOpenSearch
+	default:
+		return models.DiscoveredAsset{}, false
+	}
+	return asset, true
+}
+
+/*
+applyDiscovery stores the discovered assets of a page, adding the
+implicit /favicon.ico when no icon is declared. With ANALYZE_CHECK_DISCOVERY
+enabled every asset is fetched and validated, and failures are counted in
+DiscoveryIssueCount.
+*/
+func applyDiscovery(ctx context.Context, result *models.AnalysisResult, client *http.Client, pageURL string, declared []models.DiscoveredAsset) {
+	assets := []models.DiscoveredAsset{}
+	seen := map[string]bool{}
+	hasIcon := false
+	for _, a := range declared {
+		if !hasSchemePrefix(a.URL, "http:", "https:") || seen[a.Kind+" "+a.URL] {
+			continue
+		}
+		seen[a.Kind+" "+a.URL] = true
+		hasIcon = hasIcon || a.Kind == AssetIcon
+		assets = append(assets, a)
+	}
+	if !hasIcon {
+		if base, err := url.Parse(pageURL); err == nil && base.Host != "" {
+			favicon := url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/favicon.ico"}
+			assets = append(assets, models.DiscoveredAsset{Kind: AssetIcon, URL: favicon.String(), Implicit: true})
+		}
+	}
+
+	if config.Analyzer.CheckDiscovery {
+		checkDiscoveredAssets(ctx, client, assets)
+	}
+
+	result.DiscoveryIssueCount = 0
+	for _, a := range assets {
+		if a.Valid != nil && !*a.Valid {
+			result.DiscoveryIssueCount++
+		}
+	}
+	result.Discovered = assets
+}
+
+// checkDiscoveredAssets fetches and validates assets concurrently.
+func checkDiscoveredAssets(ctx context.Context, client *http.Client, assets []models.DiscoveredAsset) {
+	workers := config.Analyzer.LinkCheckWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	semaphore := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+	for i := range assets {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(a *models.DiscoveredAsset) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			checkDiscoveredAsset(ctx, client, a)
+		}(&assets[i])
+	}
+	wg.Wait()
+}
+
+/*
+checkDiscoveredAsset fetches a single asset and validates it by kind:
+feeds and OpenSearch descriptors must parse as XML (or JSON for JSON
+feeds) with their required elements, manifests must be JSON with the
+fields needed for installation, and icons must be reachable images.
+*/
+func checkDiscoveredAsset(ctx context.Context, client *http.Client, a *models.DiscoveredAsset) {
+	_, proceed, err := robotsCheck(ctx, client, a.URL)
+	if errors.Is(err, errHostThrottled) || (err == nil && !proceed) {
+		return
+	}
+	a.Checked = true
+	valid := false
+	a.Valid = &valid
+	if !proceed {
+		a.Error = err.Error()
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.URL, nil)
+	if err != nil {
+		a.Error = err.Error()
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		a.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	a.StatusCode = resp.StatusCode
+	a.ContentType = resp.Header.Get("Content-Type")
+	if resp.StatusCode >= 400 {
+		a.Error = "unreachable: " + resp.Status
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, config.Analyzer.ResourceMaxBytes))
+	if err != nil {
+		a.Error = err.Error()
+		return
+	}
+
+	switch a.Kind {
+	case AssetFeed:
+		err = validateFeed(a, body)
+	case AssetManifest:
+		err = validateManifest(a, body)
+	case AssetOpenSearch:
+		err = validateOpenSearch(a, body)
+	case AssetIcon, AssetAppleTouchIcon:
+		err = validateIcon(a.ContentType, body)
+	}
+	if err != nil {
+		a.Error = err.Error()
+		return
+	}
+	valid = len(a.Missing) == 0
+}
+
+// feedDocument covers the elements of RSS 2.0, RSS 1.0 (RDF) and Atom needed for validation.
+type feedDocument struct {
+	XMLName xml.Name
+	Title   string `xml:"title"`
+	ID      string `xml:"id"`
+	Updated string `xml:"updated"`
+	Channel *struct {
+		Title       string   `xml:"title"`
+		Links       []string `xml:"link"`
+		Description string   `xml:"description"`
+	} `xml:"channel"`
+}
+
+/*
+validateFeed parses an RSS, RDF, Atom or JSON feed and records its
+format, title and missing required elements.
+*/
+func validateFeed(a *models.DiscoveredAsset, body []byte) error {
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "{") {
+		var feed map[string]interface{}
+		if err := json.Unmarshal(body, &feed); err != nil {
+			return err
+		}
+		a.Format = "json"
+		a.Name, _ = feed["title"].(string)
+		a.Missing = missingFields(feed, "version", "title", "items")
+		return nil
+	}
+
+	var feed feedDocument
+	if err := decodeXML(body, &feed); err != nil {
+		return err
+	}
+	switch strings.ToLower(feed.XMLName.Local) {
+	case "rss", "rdf":
+		a.Format = "rss"
+		if feed.XMLName.Local != "rss" {
+			a.Format = "rdf"
+		}
+		if feed.Channel == nil {
+			a.Missing = []string{"channel"}
+			return nil
+		}
+		a.Name = strings.TrimSpace(feed.Channel.Title)
+		if a.Name == "" {
+			a.Missing = append(a.Missing, "channel.title")
+		}
+		hasLink := false
+		for _, l := range feed.Channel.Links {
+			hasLink = hasLink || strings.TrimSpace(l) != ""
+		}
+		if !hasLink {
+			a.Missing = append(a.Missing, "channel.link")
+		}
+		if a.Format == "rss" && strings.TrimSpace(feed.Channel.Description) == "" {
+			a.Missing = append(a.Missing, "channel.description")
+		}
+	case "feed":
+		a.Format = "atom"
+		a.Name = strings.TrimSpace(feed.Title)
+		a.Missing = missingFields(map[string]interface{}{
+			"id":      feed.ID,
+			"title":   feed.Title,
+			"updated": feed.Updated,
+		}, "id", "title", "updated")
+	default:
+		return fmt.Errorf("unexpected feed root element <%s>", feed.XMLName.Local)
+	}
+	return nil
+}
+
+/*
+validateManifest parses a web app manifest and records the fields
+required for installation that it lacks: a name or short_name, icons,
+start_url and display.
+*/
+func validateManifest(a *models.DiscoveredAsset, body []byte) error {
+	var manifest map[string]interface{}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return err
+	}
+	a.Name, _ = manifest["name"].(string)
+	if a.Name == "" {
+		a.Name, _ = manifest["short_name"].(string)
+	}
+	if !hasAnyProperty(manifest, []string{"name", "short_name"}) {
+		a.Missing = append(a.Missing, "name|short_name")
+	}
+	a.Missing = append(a.Missing, missingFields(manifest, "icons", "start_url", "display")...)
+	return nil
+}
+
+// openSearchDocument covers the elements of an OpenSearch description needed for validation.
+type openSearchDocument struct {
+	XMLName     xml.Name
+	ShortName   string `xml:"ShortName"`
+	Description string `xml:"Description"`
+	URLs        []struct {
+		Template string `xml:"template,attr"`
+	} `xml:"Url"`
+}
+
+/*
+validateOpenSearch parses an OpenSearch description and records missing
+ShortName, Description and Url template elements.
+*/
+func validateOpenSearch(a *models.DiscoveredAsset, body []byte) error {
+	var desc openSearchDocument
+	if err := decodeXML(body, &desc); err != nil {
+		return err
+	}
+	if desc.XMLName.Local != "OpenSearchDescription" {
+		return fmt.Errorf("unexpected OpenSearch root element <%s>", desc.XMLName.Local)
+	}
+	a.Name = strings.TrimSpace(desc.ShortName)
+	if a.Name == "" {
+		a.Missing = append(a.Missing, "ShortName")
+	}
+	if strings.TrimSpace(desc.Description) == "" {
+		a.Missing = append(a.Missing, "Description")
+	}
+	hasTemplate := false
+	for _, u := range desc.URLs {
+		hasTemplate = hasTemplate || strings.TrimSpace(u.Template) != ""
+	}
+	if !hasTemplate {
+		a.Missing = append(a.Missing, "Url.template")
+	}
+	return nil
+}
+
+// validateIcon checks that an icon response is an image, by Content-Type or by sniffing the body.
+func validateIcon(contentType string, body []byte) error {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && strings.HasPrefix(mediaType, "image/") {
+		return nil
+	}
+	if strings.HasPrefix(http.DetectContentType(body), "image/") {
+		return nil
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	return fmt.Errorf("not an image (%s)", contentType)
+}
+
+// decodeXML unmarshals an XML document, honoring its declared character set.
+func decodeXML(body []byte, v interface{}) error {
+	decoder := xml.NewDecoder(strings.NewReader(string(body)))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder.Decode(v)
+}
+
+// missingFields returns the names of fields that doc lacks or leaves empty.
+func missingFields(doc map[string]interface{}, fields ...string) []string {
+	var missing []string
+	for _, field := range fields {
+		if !hasProperty(doc, field) {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/ledongthuc/pdf"
+)
+
+// Document types recorded in AnalysisResult.DocumentType.
+const (
+	DocumentHTML = "html"
+	DocumentPDF  = "pdf"
+	DocumentText = "text"
+)
+
+// sniffLen is the number of leading bytes inspected to detect the document type.
+const sniffLen = 512
+
+// textMediaTypes are the plain-text content types analyzed as text documents.
+var textMediaTypes = map[string]bool{
+	"text/plain":      true,
+	"text/markdown":   true,
+	"text/x-markdown": true,
+	"text/csv":        true,
+}
+
+// textURL matches absolute http(s) URLs in free text.
+var textURL = regexp.MustCompile(`https?://[^\s<>"'(){}\[\]]+`)
+
+/*
+detectDocumentType classifies a response as HTML, PDF or plain text from
+its Content-Type and, when that is missing or generic, from its leading
+bytes. A body starting with the PDF signature is always treated as a PDF,
+since servers often label PDFs application/octet-stream.
+*/
+func detectDocumentType(contentType string, head []byte) string {
+	if bytes.HasPrefix(head, []byte("%PDF-")) {
+		return DocumentPDF
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/pdf", mediaType == "application/x-pdf":
+		return DocumentPDF
+	case textMediaTypes[mediaType]:
+		return DocumentText
+	case mediaType == "", mediaType == "application/octet-stream":
+		if sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head)); sniffed == "text/plain" {
+			return DocumentText
+		}
+	}
+	return DocumentHTML
+}
+
+/*
+analyzeDocument completes the analysis of a PDF or plain-text response
+whose body is read from r (up to ANALYZE_DOCUMENT_MAX_BYTES). It records
+the type-specific results (title, page count, metadata and embedded
+links of PDFs; line count and URLs of text files) and the parts of the
+HTML analysis that still apply: text metrics, language, PII, link checks,
+third parties, caching headers and cookies.
+*/
+func analyzeDocument(ctx context.Context, u *models.URL, client *http.Client, resp *http.Response, r io.Reader, body *countingReader, digest hash.Hash, timer *fetchTimer, cookies *cookieRecorder) error {
+	data, err := io.ReadAll(io.LimitReader(r, config.Analyzer.DocumentMaxBytes))
+	u.PageTiming = timer.finish()
+	if err != nil {
+		return err
+	}
+
+	// Release the connection now: link checks may need a slot for the same host
+	resp.Body.Close()
+
+	var text string
+	var refs []string
+	switch u.DocumentType {
+	case DocumentPDF:
+		if int64(len(data)) >= config.Analyzer.DocumentMaxBytes {
+			return errors.New("PDF exceeds ANALYZE_DOCUMENT_MAX_BYTES")
+		}
+		if text, refs, err = analyzePDF(&u.AnalysisResult, data); err != nil {
+			return fmt.Errorf("invalid PDF: %w", err)
+		}
+	case DocumentText:
+		text = string(data)
+		u.LineCount = countLines(text)
+		refs = textURLs(text)
+	}
+
+	pageURL := resp.Request.URL.String()
+	pageHost := resp.Request.URL.Hostname()
+	var hrefs []string
+	u.InternalLinksCount = 0
+	u.ExternalLinksCount = 0
+	for _, ref := range refs {
+		abs := resolveURL(pageURL, ref)
+		if !hasSchemePrefix(abs, "http:", "https:") {
+			continue
+		}
+		if strings.EqualFold(extractHost(abs), pageHost) {
+			u.InternalLinksCount++
+		} else {
+			u.ExternalLinksCount++
+		}
+		hrefs = append(hrefs, abs)
+	}
+
+	u.ContentHash = hex.EncodeToString(digest.Sum(nil))
+	u.HTMLBytes = body.n
+	u.PageWeightBytes = body.n
+	u.PageCache = cacheHeadersFrom(resp)
+	u.PageCache.CacheIssues = auditCacheHeaders(u.PageCache, resp.Header.Get("Content-Type"), body.n, false)
+	u.CacheIssueCount = len(u.PageCache.CacheIssues)
+
+	applyTextMetrics(&u.AnalysisResult, text, body.n)
+	applyLanguage(&u.AnalysisResult, "", resp.Header.Get("Content-Language"), text)
+	u.PII = extractPII(nil, text)
+	u.PIICount = len(u.PII)
+
+	applyLinks(ctx, &u.AnalysisResult, client, pageHost, hrefs)
+	applyCookies(&u.AnalysisResult, pageHost, cookies)
+	applyThirdParties(&u.AnalysisResult, pageHost, hrefs, nil)
+	return nil
+}
+
+/*
+analyzePDF records the page count, the Info dictionary (Title, Author,
+Creator, Producer, dates...) as metadata and the title of a PDF, and
+returns its extracted text and the URIs of its link annotations and text.
+Text extraction is best effort: documents whose text cannot be decoded
+still report their structure.
+*/
+func analyzePDF(r *models.AnalysisResult, data []byte) (text string, uris []string, err error) {
+	// The PDF reader panics on some malformed documents
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", nil, err
+	}
+	r.PageCount = reader.NumPage()
+
+	info := reader.Trailer().Key("Info")
+	keys := info.Keys()
+	sort.Strings(keys)
+	var meta []models.MetaTag
+	for _, key := range keys {
+		v := info.Key(key)
+		value := ""
+		switch v.Kind() {
+		case pdf.String:
+			value = strings.TrimSpace(v.Text())
+		case pdf.Name:
+			value = v.Name()
+		}
+		if value == "" {
+			continue
+		}
+		meta = append(meta, models.MetaTag{Name: key, Content: value})
+		if key == "Title" {
+			r.PageTitle = value
+		}
+	}
+	r.Meta = meta
+
+	for i := 1; i <= r.PageCount; i++ {
+		annots := reader.Page(i).V.Key("Annots")
+		for j := 0; j < annots.Len(); j++ {
+			a := annots.Index(j)
+			if a.Key("Subtype").Name() != "Link" {
+				continue
+			}
+			if uri := strings.TrimSpace(a.Key("A").Key("URI").RawString()); uri != "" {
+				uris = append(uris, uri)
+			}
+		}
+	}
+
+	if plain, perr := reader.GetPlainText(); perr == nil {
+		if b, rerr := io.ReadAll(plain); rerr == nil {
+			text = string(b)
+			uris = append(uris, textURLs(text)...)
+		}
+	}
+	return text, uris, nil
+}
+
+// countLines returns the number of lines of a text document, counting a final unterminated line.
+func countLines(text string) int {
+	if text == "" {
+		return 0
+	}
+	n := strings.Count(text, "\n")
+	if !strings.HasSuffix(text, "\n") {
+		n++
+	}
+	return n
+}
+
+// textURLs returns the http(s) URLs found in free text, without trailing punctuation.
+func textURLs(text string) []string {
+	var urls []string
+	for _, m := range textURL.FindAllString(text, -1) {
+		if m = strings.TrimRight(m, ".,;:!?'\""); len(m) > len("https://") {
+			urls = append(urls, m)
+		}
+	}
+	return urls
+}
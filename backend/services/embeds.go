@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/DMequanint/url-analyzer-pro/pkg/websockethub"
+	"golang.org/x/net/html"
+)
+
+// Embed audit findings recorded in Embed.Issues.
+const (
+	EmbedIssueNoSandbox     = "no-sandbox"     // Frame without a sandbox attribute
+	EmbedIssueNoTitle       = "no-title"       // Frame without an accessible title
+	EmbedIssueSandboxEscape = "sandbox-escape" // Same-origin frame allowing scripts and same-origin access, which can remove its sandbox
+)
+
+// Embed categories recorded in Embed.Category.
+const (
+	EmbedVideo  = "video"
+	EmbedMap    = "map"
+	EmbedForm   = "form"
+	EmbedAd     = "ad"
+	EmbedSocial = "social"
+)
+
+// embedProviders maps well-known embed hosts (and subdomains), optionally restricted to a path prefix, to a category.
+var embedProviders = []struct {
+	domain   string
+	path     string
+	category string
+}{
+	{"youtube.com", "", EmbedVideo},
+	{"youtube-nocookie.com", "", EmbedVideo},
+	{"vimeo.com", "", EmbedVideo},
+	{"dailymotion.com", "", EmbedVideo},
+	{"wistia.net", "", EmbedVideo},
+	{"twitch.tv", "", EmbedVideo},
+	{"loom.com", "", EmbedVideo},
+	{"maps.google.com", "", EmbedMap},
+	{"google.com", "/maps", EmbedMap},
+	{"openstreetmap.org", "", EmbedMap},
+	{"mapbox.com", "", EmbedMap},
+	{"bing.com", "/maps", EmbedMap},
+	{"docs.google.com", "/forms", EmbedForm},
+	{"typeform.com", "", EmbedForm},
+	{"jotform.com", "", EmbedForm},
+	{"forms.office.com", "", EmbedForm},
+	{"hsforms.com", "", EmbedForm},
+	{"formstack.com", "", EmbedForm},
+	{"doubleclick.net", "", EmbedAd},
+	{"googlesyndication.com", "", EmbedAd},
+	{"amazon-adsystem.com", "", EmbedAd},
+	{"facebook.com", "/plugins", EmbedSocial},
+	{"platform.twitter.com", "", EmbedSocial},
+	{"instagram.com", "", EmbedSocial},
+	{"linkedin.com", "/embed", EmbedSocial},
+}
+
+// childFrameMu serializes child frame queuing so concurrent workers never queue the same frame twice.
+var childFrameMu sync.Mutex
+
+/*
+collectEmbeds walks the document for <iframe>, <frame>, <embed> and
+<object> elements, resolving their source against pageURL, classifying
+the source host and auditing frames for sandboxing and titles. Frames
+without a source (srcdoc or about:blank) share the page's origin.
+*/
+func collectEmbeds(doc *html.Node, pageURL string) []models.Embed {
+	page, _ := url.Parse(pageURL)
+	embeds := []models.Embed{}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch tag := strings.ToLower(n.Data); tag {
+			case "iframe", "frame", "embed", "object":
+				embeds = append(embeds, embedElement(n, tag, page))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return embeds
+}
+
+// embedElement records one embedding element and its audit findings.
+func embedElement(n *html.Node, tag string, page *url.URL) models.Embed {
+	e := models.Embed{
+		Tag:     tag,
+		Title:   strings.TrimSpace(attrValue(n, "title")),
+		Allow:   strings.TrimSpace(attrValue(n, "allow")),
+		Loading: strings.ToLower(strings.TrimSpace(attrValue(n, "loading"))),
+		Type:    strings.TrimSpace(attrValue(n, "type")),
+	}
+
+	src := strings.TrimSpace(attrValue(n, "src"))
+	if tag == "object" {
+		src = strings.TrimSpace(attrValue(n, "data"))
+	}
+	if src != "" && !strings.EqualFold(src, "about:blank") && page != nil {
+		e.URL = resolveURL(page.String(), src)
+	}
+	if target, err := url.Parse(e.URL); err == nil && e.URL != "" && page != nil {
+		e.Host = strings.ToLower(target.Hostname())
+		e.SameOrigin = strings.EqualFold(target.Scheme, page.Scheme) &&
+			e.Host == strings.ToLower(page.Hostname()) &&
+			effectivePort(target) == effectivePort(page)
+		e.ThirdParty = e.Host != "" && registrableDomain(e.Host) != registrableDomain(page.Hostname())
+		e.Category = embedCategory(target)
+	} else if e.URL == "" && (tag == "iframe" || tag == "frame") {
+		e.SameOrigin = true
+	}
+
+	if sandbox, ok := attrLookup(n, "sandbox"); ok {
+		e.Sandboxed = true
+		for _, token := range strings.Fields(strings.ToLower(sandbox)) {
+			if !containsString(e.Sandbox, token) {
+				e.Sandbox = append(e.Sandbox, token)
+			}
+		}
+	}
+	e.Issues = auditEmbed(e)
+	return e
+}
+
+// embedCategory returns the category of a well-known embed provider, falling back to the tracker list.
+func embedCategory(target *url.URL) string {
+	host := strings.ToLower(target.Hostname())
+	for _, p := range embedProviders {
+		if domainMatches(host, p.domain) && strings.HasPrefix(target.Path, p.path) {
+			return p.category
+		}
+	}
+	for _, entry := range lookupTracker(host) {
+		switch entry.Category {
+		case "advertising":
+			return EmbedAd
+		case "social":
+			return EmbedSocial
+		}
+	}
+	return ""
+}
+
+// auditEmbed returns the findings of a frame; <embed> and <object> have no sandbox attribute and are not audited.
+func auditEmbed(e models.Embed) []string {
+	if e.Tag != "iframe" && e.Tag != "frame" {
+		return nil
+	}
+	var issues []string
+	if !e.Sandboxed {
+		issues = append(issues, EmbedIssueNoSandbox)
+	}
+	if e.Title == "" {
+		issues = append(issues, EmbedIssueNoTitle)
+	}
+	if e.Sandboxed && e.SameOrigin && containsString(e.Sandbox, "allow-scripts") && containsString(e.Sandbox, "allow-same-origin") {
+		issues = append(issues, EmbedIssueSandboxEscape)
+	}
+	return issues
+}
+
+// applyEmbeds records the embedded content of a page and counts its findings.
+func applyEmbeds(r *models.AnalysisResult, doc *html.Node, pageURL string) {
+	embeds := collectEmbeds(doc, pageURL)
+	r.EmbedCount = len(embeds)
+	r.EmbedIssueCount = 0
+	for _, e := range embeds {
+		r.EmbedIssueCount += len(e.Issues)
+	}
+	r.Embeds = embeds
+}
+
+/*
+QueueChildFrames queues the same-origin frames of a freshly analyzed page
+for analysis as child pages, when ANALYZE_CHILD_FRAMES is enabled.
+
+At most ANALYZE_CHILD_FRAME_LIMIT frames are queued per page. Frames that
+are already child pages of the page are queued again rather than
+duplicated, and new ones must be allowed by the robots.txt policy. Frames
+of the page itself are skipped. Child pages inherit the request profile of
+the page but not its crawl, and their own frames are not followed.
+*/
+func QueueChildFrames(u *models.URL) error {
+	if !config.Analyzer.AnalyzeChildFrames || u.ParentURLID != nil {
+		return nil
+	}
+
+	// Children known before the robots checks need none; the set is read
+	// again under childFrameMu, since another worker may queue frames meanwhile
+	existing, err := childFrames(u.ID)
+	if err != nil {
+		return err
+	}
+
+	profile, err := DecryptRequestProfile(u.RequestProfile)
+	if err != nil {
+		return err
+	}
+	client := newHTTPClient(profile, u.URL)
+	defer client.CloseIdleConnections()
+
+	// Robots checks may wait on politeness limits, so they run outside childFrameMu
+	type candidate struct {
+		url        string
+		normalized string
+		allowed    bool // Checked against robots.txt for a new child page
+	}
+	var candidates []candidate
+	self, _ := NormalizeURL(u.URL)
+	seen := map[string]bool{self: true}
+	for _, e := range u.Embeds {
+		if (e.Tag != "iframe" && e.Tag != "frame") || !e.SameOrigin || !hasSchemePrefix(e.URL, "http:", "https:") {
+			continue
+		}
+		normalized, err := NormalizeURL(e.URL)
+		if err != nil || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+
+		c := candidate{url: e.URL, normalized: normalized}
+		if _, ok := existing[normalized]; !ok {
+			// Frames whose robots.txt is unreachable are queued; their analysis reports the error
+			_, proceed, err := robotsCheck(context.Background(), client, e.URL)
+			c.allowed = err != nil || proceed
+		}
+		candidates = append(candidates, c)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	childFrameMu.Lock()
+	defer childFrameMu.Unlock()
+
+	if existing, err = childFrames(u.ID); err != nil {
+		return err
+	}
+	queued := 0
+	for _, c := range candidates {
+		if queued >= config.Analyzer.ChildFrameLimit {
+			break
+		}
+
+		if child, ok := existing[c.normalized]; ok {
+			if child.Status == "queued" || child.Status == "running" {
+				continue
+			}
+			if err := config.DB.Model(child).Update("status", "queued").Error; err != nil {
+				return err
+			}
+			queued++
+			websockethub.BroadcastStatusUpdate(map[string]interface{}{
+				"id":          child.ID,
+				"status":      "queued",
+				"parentUrlId": u.ID,
+			})
+			continue
+		}
+		if !c.allowed {
+			continue
+		}
+
+		parentID := u.ID
+		child := models.URL{
+			URL:               c.url,
+			NormalizedURL:     c.normalized,
+			Status:            "queued",
+			JobType:           JobTypePage,
+			ParentURLID:       &parentID,
+			RequestProfile:    u.RequestProfile,
+			HasRequestProfile: u.HasRequestProfile,
+		}
+		if err := config.DB.Create(&child).Error; err != nil {
+			return err
+		}
+		queued++
+
+		websockethub.BroadcastStatusUpdate(map[string]interface{}{
+			"id":          child.ID,
+			"url":         child.URL,
+			"status":      child.Status,
+			"parentUrlId": u.ID,
+		})
+	}
+	return nil
+}
+
+// childFrames returns the child pages of a URL keyed by normalized URL.
+func childFrames(parentID string) (map[string]*models.URL, error) {
+	var children []models.URL
+	if err := config.DB.Where("parent_url_id = ?", parentID).Find(&children).Error; err != nil {
+		return nil, err
+	}
+	existing := make(map[string]*models.URL, len(children))
+	for i := range children {
+		if normalized, err := NormalizeURL(children[i].URL); err == nil {
+			existing[normalized] = &children[i]
+		}
+	}
+	return existing, nil
+}
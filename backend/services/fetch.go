@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+)
+
+/*
+newHTTPClient returns the client used for every request the analyzer makes
+for a URL: the page itself, robots.txt, subresources and link checks.
+
+Requests carry the analyzer User-Agent and, when profile is set, the URL's
+request profile (see profileTransport); pageURL is the analyzed page,
+whose host may receive the profile's credentials. Traffic goes through the configured
+outbound proxy, if any (see proxyFunc), and is subject to the shared
+per-host politeness limits (see hostLimiter). Each request is bounded by the
+configured per-request timeout, which starts once the limiter lets it go
+out (see rateLimitTransport), so a single slow host cannot stall the
+analysis.
+*/
+func newHTTPClient(profile *models.RequestProfile, pageURL string) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(profile)
+
+	var pageHost, pageScheme string
+	if page, err := url.Parse(pageURL); err == nil {
+		pageHost, pageScheme = page.Hostname(), strings.ToLower(page.Scheme)
+	}
+
+	return &http.Client{
+		Transport: &cookieTransport{
+			base: &profileTransport{
+				base:    &rateLimitTransport{base: transport},
+				profile: profile,
+				host:    pageHost,
+				scheme:  pageScheme,
+			},
+		},
+	}
+}
+
+/*
+fetchResources probes up to config.Analyzer.ResourceFetchLimit resources
+concurrently and records their size, content type and cache headers in place.
+Resources beyond the limit, or disallowed by robots.txt under the "obey"
+policy, are left unfetched with an unknown size.
+*/
+func fetchResources(ctx context.Context, client *http.Client, resources []models.Resource) {
+	limit := config.Analyzer.ResourceFetchLimit
+	if limit > len(resources) {
+		limit = len(resources)
+	}
+
+	workers := config.Analyzer.ResourceWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	semaphore := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(r *models.Resource) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			_, proceed, err := robotsCheck(ctx, client, r.URL)
+			switch {
+			case errors.Is(err, errHostThrottled):
+				r.FetchError = err.Error()
+				return
+			case err != nil && !proceed:
+				r.Fetched = true
+				r.FetchError = err.Error()
+				return
+			case !proceed:
+				r.FetchError = "disallowed by robots.txt"
+				return
+			}
+			probeResource(ctx, client, r)
+		}(&resources[i])
+	}
+	wg.Wait()
+}
+
+/*
+probeResource fills in the response metadata of a single resource.
+
+A HEAD request is tried first. If the server rejects it or does not report
+a Content-Length, the resource is fetched with GET and its body is counted
+up to config.Analyzer.ResourceMaxBytes; larger bodies keep the size read so
+far, which makes the page weight a lower-bound estimate. Only successful
+(2xx) responses are sized: error pages say nothing about the resource, so
+failed fetches keep an unknown size.
+*/
+func probeResource(ctx context.Context, client *http.Client, r *models.Resource) {
+	r.Fetched = true
+
+	resp, err := probeRequest(ctx, client, http.MethodHead, r.URL)
+	if errors.Is(err, errHostThrottled) {
+		r.Fetched = false
+		r.FetchError = errHostThrottled.Error()
+		return
+	}
+	if err == nil {
+		resp.Body.Close()
+		if successStatus(resp.StatusCode) && resp.ContentLength >= 0 {
+			applyResourceHeaders(r, resp)
+			r.Size = resp.ContentLength
+			auditResource(r)
+			return
+		}
+	}
+
+	resp, err = probeRequest(ctx, client, http.MethodGet, r.URL)
+	if errors.Is(err, errHostThrottled) {
+		r.Fetched = false
+		r.FetchError = errHostThrottled.Error()
+		return
+	}
+	if err != nil {
+		r.FetchError = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+
+	applyResourceHeaders(r, resp)
+	if !successStatus(resp.StatusCode) {
+		return
+	}
+	n, _ := io.Copy(io.Discard, io.LimitReader(resp.Body, config.Analyzer.ResourceMaxBytes))
+	r.Size = n
+	auditResource(r)
+}
+
+// successStatus reports whether an HTTP status is 2xx.
+func successStatus(code int) bool {
+	return code >= 200 && code < 300
+}
+
+/*
+probeRequest sends a resource probe advertising compression support. The
+explicit Accept-Encoding keeps the transport from decompressing the body,
+so sizes are the bytes transferred and Content-Encoding is reported as sent.
+*/
+func probeRequest(ctx context.Context, client *http.Client, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	return client.Do(req)
+}
+
+// applyResourceHeaders copies the status and caching-related headers of resp onto r.
+func applyResourceHeaders(r *models.Resource, resp *http.Response) {
+	r.StatusCode = resp.StatusCode
+	r.ContentType = resp.Header.Get("Content-Type")
+	r.CacheHeaders = cacheHeadersFrom(resp)
+}
+
+// auditResource records the caching and compression findings of a successfully fetched resource.
+func auditResource(r *models.Resource) {
+	if successStatus(r.StatusCode) {
+		r.CacheIssues = auditCacheHeaders(r.CacheHeaders, r.ContentType, r.Size, staticResourceTypes[r.Type])
+	}
+}
+
+/*
+uniqueResources drops duplicate references to the same URL, keeping the
+first occurrence. Form targets are excluded since they are not loaded with
+the page and do not contribute to its weight.
+*/
+func uniqueResources(resources []models.Resource) []models.Resource {
+	seen := make(map[string]bool, len(resources))
+	unique := make([]models.Resource, 0, len(resources))
+	for _, r := range resources {
+		if r.Type == "form" || seen[r.URL] {
+			continue
+		}
+		seen[r.URL] = true
+		unique = append(unique, r)
+	}
+	return unique
+}
+
+/*
+countingReader wraps an io.Reader and counts the bytes read through it.
+When the underlying reader is exhausted (or fails), onEnd is called once,
+which lets the page timer stop at the end of the body rather than after
+parsing.
+*/
+type countingReader struct {
+	r     io.Reader
+	n     int64
+	onEnd func()
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if err != nil && c.onEnd != nil {
+		c.onEnd()
+		c.onEnd = nil
+	}
+	return n, err
+}
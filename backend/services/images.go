@@ -0,0 +1,244 @@
+package services
+
+import (
+	"mime"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"golang.org/x/net/html"
+)
+
+// Image audit findings recorded in Image.Issues.
+const (
+	ImageIssueMissingAlt   = "missing-alt"
+	ImageIssueOversized    = "oversized"
+	ImageIssueLegacyFormat = "legacy-format"
+)
+
+// legacyImageFormats are raster formats with a smaller modern replacement (WebP, AVIF).
+var legacyImageFormats = map[string]bool{"jpeg": true, "png": true, "gif": true, "bmp": true, "tiff": true}
+
+// modernImageFormats are the formats that count as a modern alternative.
+var modernImageFormats = map[string]bool{"webp": true, "avif": true, "jxl": true}
+
+var (
+	// cssBackground matches background and background-image declarations.
+	cssBackground = regexp.MustCompile(`(?i)background(?:-image)?\s*:([^;}]*)`)
+	// cssURL matches url(...) references with optional quotes.
+	cssURL = regexp.MustCompile(`(?i)url\(\s*['"]?([^'")]+?)['"]?\s*\)`)
+)
+
+/*
+collectImages walks the document for <img> elements (noting the formats
+offered by sibling <source> elements of a <picture>) and for background
+images declared in style attributes and <style> blocks. Background images
+of external stylesheets are not included.
+*/
+func collectImages(doc *html.Node, pageURL string) []models.Image {
+	images := []models.Image{}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			tag := strings.ToLower(n.Data)
+			switch tag {
+			case "img":
+				images = append(images, imgElement(n, pageURL))
+			case "style":
+				for _, ref := range cssImageURLs(nodeRawText(n)) {
+					images = append(images, cssImage(ref, pageURL))
+				}
+			}
+			if style := attrValue(n, "style"); style != "" {
+				for _, ref := range cssImageURLs(style) {
+					images = append(images, cssImage(ref, pageURL))
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return images
+}
+
+// imgElement records an <img>, including the alternatives of an enclosing <picture>.
+func imgElement(n *html.Node, pageURL string) models.Image {
+	alt, hasAlt := attrLookup(n, "alt")
+	img := models.Image{
+		Source:  "img",
+		Alt:     strings.TrimSpace(alt),
+		HasAlt:  hasAlt,
+		Width:   dimension(attrValue(n, "width")),
+		Height:  dimension(attrValue(n, "height")),
+		Srcset:  strings.TrimSpace(attrValue(n, "srcset")),
+		Sizes:   strings.TrimSpace(attrValue(n, "sizes")),
+		Loading: strings.ToLower(strings.TrimSpace(attrValue(n, "loading"))),
+		Size:    -1,
+	}
+
+	src := strings.TrimSpace(attrValue(n, "src"))
+	if src == "" {
+		if candidates := parseSrcset(img.Srcset); len(candidates) > 0 {
+			src = candidates[0]
+		}
+	}
+	if src != "" && !hasSchemePrefix(src, "data:") {
+		img.URL = resolveURL(pageURL, src)
+	}
+	img.Format = imageFormat(img.URL, "")
+
+	if p := n.Parent; p != nil && strings.EqualFold(p.Data, "picture") {
+		img.Source = "picture"
+		seen := map[string]bool{}
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || !strings.EqualFold(c.Data, "source") {
+				continue
+			}
+			format := imageFormat("", attrValue(c, "type"))
+			if format == "" {
+				if candidates := parseSrcset(attrValue(c, "srcset")); len(candidates) > 0 {
+					format = imageFormat(candidates[0], "")
+				}
+			}
+			if format != "" && !seen[format] {
+				seen[format] = true
+				img.Alternatives = append(img.Alternatives, format)
+			}
+		}
+	}
+	return img
+}
+
+// cssImage records a background image referenced from CSS.
+func cssImage(ref, pageURL string) models.Image {
+	abs := resolveURL(pageURL, ref)
+	return models.Image{URL: abs, Source: "css", Format: imageFormat(abs, ""), Size: -1}
+}
+
+// cssImageURLs returns the url() references of the background declarations in css.
+func cssImageURLs(css string) []string {
+	var refs []string
+	for _, decl := range cssBackground.FindAllStringSubmatch(css, -1) {
+		for _, m := range cssURL.FindAllStringSubmatch(decl[1], -1) {
+			ref := strings.TrimSpace(m[1])
+			if ref != "" && !hasSchemePrefix(ref, "data:") {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// cssImageRefs turns the CSS background images of a page into subresource references.
+func cssImageRefs(images []models.Image) []models.Resource {
+	var refs []models.Resource
+	for _, img := range images {
+		if img.Source == "css" && img.URL != "" {
+			refs = append(refs, models.Resource{
+				URL:       img.URL,
+				Host:      extractHost(img.URL),
+				Tag:       "style",
+				Attribute: "background-image",
+				Type:      "image",
+				Size:      -1,
+			})
+		}
+	}
+	return refs
+}
+
+// dimension parses a declared width/height attribute, accepting values like "640" or "640px".
+func dimension(raw string) int {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(raw), "px"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+/*
+imageFormat derives an image format from a Content-Type (preferred when
+given) or from the extension of rawURL.
+*/
+func imageFormat(rawURL, contentType string) string {
+	if contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && strings.HasPrefix(mediaType, "image/") {
+			switch sub := strings.TrimPrefix(mediaType, "image/"); sub {
+			case "jpeg", "jpg", "pjpeg":
+				return "jpeg"
+			case "svg+xml":
+				return "svg"
+			case "x-icon", "vnd.microsoft.icon":
+				return "ico"
+			default:
+				return sub
+			}
+		}
+	}
+	if rawURL == "" {
+		return ""
+	}
+	p := rawURL
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+	switch ext := strings.ToLower(strings.TrimPrefix(path.Ext(p), ".")); ext {
+	case "jpg", "jpeg", "jpe", "jfif":
+		return "jpeg"
+	case "tif":
+		return "tiff"
+	case "png", "gif", "webp", "avif", "svg", "bmp", "ico", "tiff", "jxl":
+		return ext
+	}
+	return ""
+}
+
+/*
+auditImages fills in the fetched size and content-type-based format of
+each image from the subresource inventory, using only successful (2xx)
+responses since an error page says nothing about the image, and records
+its findings: a missing alt attribute on <img>, a size above
+ANALYZE_IMAGE_MAX_BYTES, and a legacy format (JPEG, PNG, GIF...) without
+a WebP/AVIF alternative.
+*/
+func auditImages(images []models.Image, inventory []models.Resource) {
+	fetched := make(map[string]models.Resource, len(inventory))
+	for _, r := range inventory {
+		if r.Fetched && successStatus(r.StatusCode) {
+			fetched[r.URL] = r
+		}
+	}
+
+	for i := range images {
+		img := &images[i]
+		if r, ok := fetched[img.URL]; ok {
+			img.Size = r.Size
+			if format := imageFormat(img.URL, r.ContentType); format != "" {
+				img.Format = format
+			}
+		}
+
+		img.Issues = nil
+		if img.Source != "css" && !img.HasAlt {
+			img.Issues = append(img.Issues, ImageIssueMissingAlt)
+		}
+		if img.Size > config.Analyzer.ImageMaxBytes {
+			img.Issues = append(img.Issues, ImageIssueOversized)
+		}
+		if legacyImageFormats[img.Format] {
+			modern := false
+			for _, alt := range img.Alternatives {
+				modern = modern || modernImageFormats[alt]
+			}
+			if !modern {
+				img.Issues = append(img.Issues, ImageIssueLegacyFormat)
+			}
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/abadojack/whatlanggo"
+	"golang.org/x/net/html"
+	"golang.org/x/text/language"
+)
+
+// minDetectWords is the visible word count below which language detection is not attempted.
+const minDetectWords = 20
+
+/*
+detectLanguage guesses the language of the visible text, returning its
+ISO 639-1 code and the detector's confidence. Short texts and languages
+without a two-letter code yield an empty code.
+*/
+func detectLanguage(text string) (string, float64) {
+	if len(textWords(text)) < minDetectWords {
+		return "", 0
+	}
+	info := whatlanggo.Detect(text)
+	return info.Lang.Iso6391(), round2(info.Confidence)
+}
+
+/*
+declaredLanguage returns the primary language subtag a page declares,
+preferring <html lang> over the first Content-Language value.
+*/
+func declaredLanguage(htmlLang, contentLanguage string) string {
+	declared := strings.TrimSpace(htmlLang)
+	if declared == "" {
+		declared = strings.TrimSpace(strings.Split(contentLanguage, ",")[0])
+	}
+	if declared == "" {
+		return ""
+	}
+	tag, err := language.Parse(declared)
+	if err != nil {
+		return strings.ToLower(strings.SplitN(declared, "-", 2)[0])
+	}
+	base, _ := tag.Base()
+	return base.String()
+}
+
+/*
+applyLanguage records the declared and detected languages on r and flags
+a mismatch when the page declares a language and the detection is
+confident enough to disagree with it.
+*/
+func applyLanguage(r *models.AnalysisResult, htmlLang, contentLanguage, text string) {
+	r.HTMLLang = strings.TrimSpace(htmlLang)
+	r.ContentLanguage = strings.TrimSpace(contentLanguage)
+	r.DetectedLanguage, r.LanguageConfidence = detectLanguage(text)
+
+	declared := declaredLanguage(r.HTMLLang, r.ContentLanguage)
+	r.LanguageMismatch = declared != "" && r.DetectedLanguage != "" &&
+		r.LanguageConfidence >= 0.5 && declared != r.DetectedLanguage
+}
+
+// hreflangRef reads a <link rel="alternate" hreflang> element, resolved against pageURL.
+func hreflangRef(n *html.Node, pageURL string) (models.HreflangAlternate, bool) {
+	code := strings.TrimSpace(attrValue(n, "hreflang"))
+	href := strings.TrimSpace(attrValue(n, "href"))
+	if code == "" || href == "" || !hasRel(n, "alternate") {
+		return models.HreflangAlternate{}, false
+	}
+	return models.HreflangAlternate{Hreflang: code, URL: resolveURL(pageURL, href)}, true
+}
+
+// validHreflang reports whether code is x-default or a well-formed BCP 47 tag.
+func validHreflang(code string) bool {
+	if strings.EqualFold(code, "x-default") {
+		return true
+	}
+	_, err := language.Parse(code)
+	return err == nil
+}
+
+// sameDocument reports whether two URLs point at the same page after normalization.
+func sameDocument(a, b string) bool {
+	na, errA := NormalizeURL(a)
+	nb, errB := NormalizeURL(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return na == nb
+}
+
+/*
+applyHreflang validates the hreflang alternates of a page and, when
+ANALYZE_CHECK_HREFLANG is enabled, fetches up to
+ANALYZE_HREFLANG_LIMIT targets to confirm they are reachable and link
+back to the page.
+*/
+func applyHreflang(ctx context.Context, r *models.AnalysisResult, client *http.Client, pageURLs []string, alternates []models.HreflangAlternate) {
+	isPage := func(target string) bool {
+		for _, p := range pageURLs {
+			if sameDocument(target, p) {
+				return true
+			}
+		}
+		return false
+	}
+
+	r.HreflangSelfReference = false
+	for i := range alternates {
+		a := &alternates[i]
+		a.ValidCode = validHreflang(a.Hreflang)
+		a.SelfReference = isPage(a.URL)
+		if a.SelfReference {
+			r.HreflangSelfReference = true
+		}
+	}
+
+	if config.Analyzer.CheckHreflang {
+		checkHreflangTargets(ctx, client, alternates, isPage)
+	}
+
+	r.HreflangIssues = 0
+	for _, a := range alternates {
+		if a.HasIssue() {
+			r.HreflangIssues++
+		}
+	}
+	if len(alternates) > 0 && !r.HreflangSelfReference {
+		r.HreflangIssues++
+	}
+	r.HreflangAlternates = alternates
+}
+
+// checkHreflangTargets fetches the non-self alternates concurrently and records reachability and reciprocity.
+func checkHreflangTargets(ctx context.Context, client *http.Client, alternates []models.HreflangAlternate, isPage func(string) bool) {
+	workers := config.Analyzer.LinkCheckWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	semaphore := make(chan struct{}, workers)
+
+	// Several codes may share a target; fetch each target once
+	results := map[string]*models.HreflangAlternate{}
+	var wg sync.WaitGroup
+	checked := 0
+	for i := range alternates {
+		a := &alternates[i]
+		if a.SelfReference || !hasSchemePrefix(a.URL, "http:", "https:") {
+			continue
+		}
+		if _, ok := results[a.URL]; ok || checked >= config.Analyzer.HreflangCheckLimit {
+			continue
+		}
+		checked++
+		results[a.URL] = &models.HreflangAlternate{URL: a.URL}
+
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(res *models.HreflangAlternate) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			*res = checkHreflangTarget(ctx, client, res.URL, isPage)
+		}(results[a.URL])
+	}
+	wg.Wait()
+
+	for i := range alternates {
+		a := &alternates[i]
+		if res, ok := results[a.URL]; ok {
+			a.Checked = res.Checked
+			a.StatusCode = res.StatusCode
+			a.Error = res.Error
+			a.Reciprocal = res.Reciprocal
+		}
+	}
+}
+
+// checkHreflangTarget fetches one alternate and looks for an hreflang link back to the page.
+func checkHreflangTarget(ctx context.Context, client *http.Client, target string, isPage func(string) bool) models.HreflangAlternate {
+	res := models.HreflangAlternate{URL: target}
+	_, proceed, err := robotsCheck(ctx, client, target)
+	if errors.Is(err, errHostThrottled) || (err == nil && !proceed) {
+		return res
+	}
+	res.Checked = true
+	if !proceed {
+		res.Error = err.Error()
+		return res
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+	res.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		return res
+	}
+
+	doc, err := html.Parse(io.LimitReader(resp.Body, config.Analyzer.ResourceMaxBytes))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	finalURL := resp.Request.URL.String()
+	reciprocal := false
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if reciprocal {
+			return
+		}
+		if n.Type == html.ElementNode && strings.EqualFold(n.Data, "link") {
+			if alt, ok := hreflangRef(n, finalURL); ok && isPage(alt.URL) {
+				reciprocal = true
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	res.Reciprocal = &reciprocal
+	return res
+}
+
+// round2 rounds f to two decimal places.
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
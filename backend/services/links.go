@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+)
+
+/*
+buildLinks de-duplicates the absolute hrefs found on a page and marks each
+as internal when it shares the page's host.
+*/
+func buildLinks(pageHost string, hrefs []string) []models.Link {
+	seen := make(map[string]bool, len(hrefs))
+	links := make([]models.Link, 0, len(hrefs))
+	for _, href := range hrefs {
+		// Fragments point at the same document and are not fetched separately
+		if i := strings.IndexByte(href, '#'); i >= 0 {
+			href = href[:i]
+		}
+		if href == "" || seen[href] {
+			continue
+		}
+		seen[href] = true
+		links = append(links, models.Link{
+			URL:      href,
+			Internal: strings.EqualFold(extractHost(href), pageHost),
+		})
+	}
+	return links
+}
+
+/*
+checkLinks probes up to config.Analyzer.LinkCheckLimit links concurrently,
+recording their status in place. Links disallowed by robots.txt are only
+probed when the robots policy is "report".
+*/
+func checkLinks(ctx context.Context, client *http.Client, links []models.Link) {
+	limit := config.Analyzer.LinkCheckLimit
+	if limit > len(links) {
+		limit = len(links)
+	}
+
+	workers := config.Analyzer.LinkCheckWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	semaphore := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(l *models.Link) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			verdict, proceed, err := robotsCheck(ctx, client, l.URL)
+			l.Robots = verdict
+			switch {
+			case errors.Is(err, errHostThrottled):
+				l.Throttled = true
+			case err != nil && !proceed:
+				l.Checked = true
+				l.Error = err.Error()
+			case proceed:
+				checkLink(ctx, client, l)
+			}
+		}(&links[i])
+	}
+	wg.Wait()
+}
+
+/*
+checkLink probes a single link with HEAD, falling back to GET for servers
+that do not implement HEAD. Only the status line is needed, so the GET
+body is closed without being read.
+*/
+func checkLink(ctx context.Context, client *http.Client, l *models.Link) {
+	l.Checked = true
+
+	resp, err := probeLink(ctx, client, l, http.MethodHead)
+	if errors.Is(err, errHostThrottled) {
+		l.Checked = false
+		l.Throttled = true
+		return
+	}
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+			l.StatusCode = resp.StatusCode
+			return
+		}
+	}
+
+	resp, err = probeLink(ctx, client, l, http.MethodGet)
+	if errors.Is(err, errHostThrottled) {
+		l.Checked = false
+		l.Throttled = true
+		return
+	}
+	if err != nil {
+		l.Error = err.Error()
+		return
+	}
+	resp.Body.Close()
+	l.StatusCode = resp.StatusCode
+}
+
+/*
+probeLink sends a single probe request for l. With ANALYZE_LINK_TIMINGS
+enabled, the request is traced and l.Timing is set to the breakdown of the
+latest probe (up to the response headers, as the body is not read).
+*/
+func probeLink(ctx context.Context, client *http.Client, l *models.Link, method string) (*http.Response, error) {
+	var timer *fetchTimer
+	if config.Analyzer.LinkTimings {
+		timer = &fetchTimer{}
+		ctx = timer.withTrace(ctx)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, l.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if timer != nil {
+		timing := timer.finish()
+		l.Timing = &timing
+	}
+	return resp, err
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"golang.org/x/net/html"
+)
+
+/*
+nodeText returns the text content of n and its descendants with runs of
+whitespace collapsed to single spaces.
+*/
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			sb.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+/*
+metaTag converts a <meta> element into a MetaTag keyed by its name,
+property or http-equiv attribute. Elements without such a key (e.g.
+<meta charset>) are skipped (ok is false).
+*/
+func metaTag(n *html.Node) (tag models.MetaTag, ok bool) {
+	name := attrValue(n, "name")
+	if name == "" {
+		name = attrValue(n, "property")
+	}
+	if name == "" {
+		name = attrValue(n, "http-equiv")
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return models.MetaTag{}, false
+	}
+	return models.MetaTag{Name: name, Content: strings.TrimSpace(attrValue(n, "content"))}, true
+}
+
+// hasRel reports whether the space-separated rel attribute of n contains value.
+func hasRel(n *html.Node, value string) bool {
+	for _, rel := range strings.Fields(strings.ToLower(attrValue(n, "rel"))) {
+		if rel == value {
+			return true
+		}
+	}
+	return false
+}
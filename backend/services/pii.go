@@ -0,0 +1,394 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"golang.org/x/net/html"
+)
+
+// Built-in PII types and the places values are found.
+const (
+	PIIEmail = "email"
+	PIIPhone = "phone"
+
+	piiSourceText   = "text"
+	piiSourceMailto = "mailto"
+	piiSourceTel    = "tel"
+)
+
+// piiPattern is a named expression matched against the visible text of a page.
+type piiPattern struct {
+	Type string
+	re   *regexp.Regexp
+}
+
+/*
+defaultPIIPatterns detect email addresses and phone numbers in text.
+Phone numbers must start with an international prefix (+) or a
+parenthesized area code, so bare digit groups such as IP addresses,
+version strings and amounts are not taken for phone numbers.
+*/
+var defaultPIIPatterns = []piiPattern{
+	{Type: PIIEmail, re: regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9-]+(?:\.[a-z0-9-]+)*\.[a-z]{2,}\b`)},
+	{Type: PIIPhone, re: regexp.MustCompile(`(?:\+\d{1,3}(?:[\s.-]?\(\d{1,5}\))?|\(\d{1,5}\))(?:[\s.-]?\d{1,5}){1,5}\b`)},
+}
+
+var (
+	// piiPatterns holds the default patterns plus any loaded from PII_PATTERNS_PATH
+	piiPatterns = defaultPIIPatterns
+
+	// piiMu guards piiPatterns against concurrent reloads
+	piiMu sync.RWMutex
+)
+
+/*
+LoadPIIPatterns reads additional PII patterns from a JSON file mapping a
+type name to a regular expression, e.g.
+
+	{
+		"iban": "\\b[A-Z]{2}\\d{2}(?: ?[A-Z0-9]{4}){3,7}\\b",
+		"ssn":  "\\b\\d{3}-\\d{2}-\\d{4}\\b"
+	}
+
+A pattern named "email" or "phone" replaces the built-in one.
+*/
+func LoadPIIPatterns(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	custom := map[string]*regexp.Regexp{}
+	for name, expr := range raw {
+		name = strings.ToLower(strings.TrimSpace(name))
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("pattern %q: %w", name, err)
+		}
+		custom[name] = re
+	}
+
+	patterns := []piiPattern{}
+	for _, p := range defaultPIIPatterns {
+		if _, ok := custom[p.Type]; !ok {
+			patterns = append(patterns, p)
+		}
+	}
+	names := make([]string, 0, len(custom))
+	for name := range custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		patterns = append(patterns, piiPattern{Type: name, re: custom[name]})
+	}
+
+	piiMu.Lock()
+	piiPatterns = patterns
+	piiMu.Unlock()
+	return nil
+}
+
+/*
+extractPII finds the emails, phone numbers and custom pattern matches in
+the visible text and in mailto:/tel: links of a page (doc may be nil for
+non-HTML documents). Values are
+normalized and de-duplicated per type, then stored according to PII_MODE.
+*/
+func extractPII(doc *html.Node, text string) []models.PIIMatch {
+	if config.Analyzer.PIIMode == config.PIIOff {
+		return nil
+	}
+
+	matches := map[string]*models.PIIMatch{}
+	var order []string
+	add := func(typ, value, source string) {
+		value = normalizePII(typ, value)
+		if value == "" {
+			return
+		}
+		key := typ + " " + value
+		m, ok := matches[key]
+		if !ok {
+			m = &models.PIIMatch{Type: typ, Value: value}
+			matches[key] = m
+			order = append(order, key)
+		}
+		m.Count++
+		for _, s := range m.Sources {
+			if s == source {
+				return
+			}
+		}
+		m.Sources = append(m.Sources, source)
+	}
+
+	piiMu.RLock()
+	patterns := piiPatterns
+	piiMu.RUnlock()
+	for _, p := range patterns {
+		for _, v := range p.re.FindAllString(text, -1) {
+			add(p.Type, v, piiSourceText)
+		}
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (strings.EqualFold(n.Data, "a") || strings.EqualFold(n.Data, "area")) {
+			href := strings.TrimSpace(attrValue(n, "href"))
+			switch {
+			case hasSchemePrefix(href, "mailto:"):
+				for _, addr := range mailtoAddresses(href) {
+					add(PIIEmail, addr, piiSourceMailto)
+				}
+			case hasSchemePrefix(href, "tel:"):
+				add(PIIPhone, href[len("tel:"):], piiSourceTel)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	if doc != nil {
+		walk(doc)
+	}
+
+	result := make([]models.PIIMatch, 0, len(order))
+	for _, key := range order {
+		m := *matches[key]
+		m.Value = storePIIValue(m.Type, m.Value)
+		result = append(result, m)
+	}
+	return result
+}
+
+// mailtoAddresses returns the recipients of a mailto: URL.
+func mailtoAddresses(href string) []string {
+	raw := href[len("mailto:"):]
+	if i := strings.IndexByte(raw, '?'); i >= 0 {
+		raw = raw[:i]
+	}
+	if unescaped, err := url.PathUnescape(raw); err == nil {
+		raw = unescaped
+	}
+	return strings.Split(raw, ",")
+}
+
+/*
+normalizePII returns the canonical form of a value: lowercased emails and
+phone numbers reduced to their digits (with a leading + when present).
+Phone matches with fewer than 7 or more than 15 digits, or that look like
+dates or dotted IPv4 addresses, are rejected by returning "".
+*/
+func normalizePII(typ, value string) string {
+	value = strings.TrimSpace(value)
+	switch typ {
+	case PIIEmail:
+		value = strings.ToLower(value)
+		if !strings.Contains(value, "@") {
+			return ""
+		}
+		return value
+	case PIIPhone:
+		if isoDate.MatchString(value) || dottedQuad.MatchString(value) {
+			return ""
+		}
+		var sb strings.Builder
+		if strings.HasPrefix(value, "+") {
+			sb.WriteByte('+')
+		}
+		digits := 0
+		for _, r := range value {
+			if r >= '0' && r <= '9' {
+				sb.WriteRune(r)
+				digits++
+			}
+		}
+		if digits < 7 || digits > 15 {
+			return ""
+		}
+		return sb.String()
+	}
+	return value
+}
+
+// dottedQuad matches IPv4-like numbers (192.168.100.200), with or without a leading +.
+var dottedQuad = regexp.MustCompile(`^\+?\d{1,3}(?:\.\d{1,3}){3}$`)
+
+// isoDate matches date-like numbers (2024-01-31, 31.01.2024) that the phone pattern would accept.
+var isoDate = regexp.MustCompile(`^(?:\d{4}[-./]\d{1,2}[-./]\d{1,2}|\d{1,2}[-./]\d{1,2}[-./]\d{4})$`)
+
+// storePIIValue applies PII_MODE to a normalized value.
+func storePIIValue(typ, value string) string {
+	switch config.Analyzer.PIIMode {
+	case config.PIIHash:
+		return hashPII(value)
+	case config.PIIRedact:
+		return redactPII(typ, value)
+	}
+	return value
+}
+
+/*
+hashPII returns the HMAC-SHA256 of a normalized value keyed with
+PII_HASH_KEY. A plain or salted hash would not protect emails and phone
+numbers, which are few enough to be recovered by hashing every candidate.
+*/
+func hashPII(value string) string {
+	mac := hmac.New(sha256.New, config.Analyzer.PIIHashKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+redactPIIText replaces every PII match in text with its masked form (see
+redactPII), whatever PII_MODE is, for copies of a response that must not
+reveal the values.
+*/
+func redactPIIText(text string) string {
+	piiMu.RLock()
+	patterns := piiPatterns
+	piiMu.RUnlock()
+	for _, p := range patterns {
+		typ := p.Type
+		text = p.re.ReplaceAllStringFunc(text, func(match string) string {
+			value := normalizePII(typ, match)
+			if value == "" {
+				return match
+			}
+			return redactPII(typ, value)
+		})
+	}
+	return text
+}
+
+/*
+redactPII masks a value while keeping enough to recognize it: the first
+character of an email's local part and its domain, the last two digits of
+a phone number, and the first and last characters of anything else.
+*/
+func redactPII(typ, value string) string {
+	switch typ {
+	case PIIEmail:
+		if at := strings.LastIndexByte(value, '@'); at > 0 {
+			return value[:1] + "***" + value[at:]
+		}
+	case PIIPhone:
+		if len(value) > 2 {
+			return strings.Repeat("*", len(value)-2) + value[len(value)-2:]
+		}
+	}
+	if len(value) <= 2 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:1] + strings.Repeat("*", len(value)-2) + value[len(value)-1:]
+}
+
+// PIIHit is a URL whose latest analysis exposes PII matching a search.
+type PIIHit struct {
+	URLID   string            `json:"urlId"`
+	URL     string            `json:"url"`
+	Matches []models.PIIMatch `json:"matches"`
+}
+
+/*
+SearchPII returns the URLs whose latest analysis exposes PII of the given
+type (optional) whose stored value contains query (optional,
+case-insensitive). When PII is stored hashed, query must be the full
+value: it is normalized and hashed before comparison.
+*/
+func SearchPII(query, typ string, limit int) ([]PIIHit, error) {
+	query = strings.TrimSpace(query)
+	typ = strings.ToLower(strings.TrimSpace(typ))
+
+	db := config.DB.Model(&models.URL{}).Where("pii_count > 0")
+	if typ != "" {
+		db = db.Where("JSON_SEARCH(pii, 'one', ?, NULL, '$[*].type') IS NOT NULL", typ)
+	}
+
+	// Stored values the query may correspond to: hashes, or substrings of
+	// the value as typed and of its phone number digits
+	var hashes map[string]bool
+	var needles []string
+	if query != "" {
+		if config.Analyzer.PIIMode == config.PIIHash {
+			hashes = map[string]bool{}
+			for _, t := range []string{PIIEmail, PIIPhone, ""} {
+				if v := normalizePII(t, query); v != "" {
+					hashes[hashPII(v)] = true
+				}
+			}
+		} else {
+			needles = append(needles, strings.ToLower(query))
+			if digits := strings.TrimPrefix(normalizePII(PIIPhone, query), "+"); digits != "" && digits != needles[0] {
+				needles = append(needles, digits)
+			}
+			// JSON_SEARCH treats % and _ as wildcards
+			escape := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+			conds := make([]string, len(needles))
+			args := make([]interface{}, len(needles))
+			for i, n := range needles {
+				conds[i] = "JSON_SEARCH(LOWER(pii), 'one', ?, NULL, '$[*].value') IS NOT NULL"
+				args[i] = "%" + escape.Replace(n) + "%"
+			}
+			db = db.Where("("+strings.Join(conds, " OR ")+")", args...)
+		}
+	}
+
+	var urls []models.URL
+	if err := db.Select("id, url, pii").Order("id").Find(&urls).Error; err != nil {
+		return nil, err
+	}
+
+	hits := []PIIHit{}
+	for _, u := range urls {
+		var matches []models.PIIMatch
+		for _, m := range u.PII {
+			if typ != "" && m.Type != typ {
+				continue
+			}
+			if query != "" {
+				if hashes != nil && !hashes[m.Value] {
+					continue
+				}
+				if hashes == nil && !containsAny(strings.ToLower(m.Value), needles) {
+					continue
+				}
+			}
+			matches = append(matches, m)
+		}
+		if len(matches) > 0 {
+			hits = append(hits, PIIHit{URLID: u.ID, URL: u.URL, Matches: matches})
+			if limit > 0 && len(hits) >= limit {
+				break
+			}
+		}
+	}
+	return hits, nil
+}
+
+// containsAny reports whether s contains any of the substrings.
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+)
+
+// withPIIMode runs a test under the given PII_MODE and hash key, restoring the previous settings afterwards.
+func withPIIMode(t *testing.T, mode string, key []byte) {
+	t.Helper()
+	prevMode, prevKey := config.Analyzer.PIIMode, config.Analyzer.PIIHashKey
+	config.Analyzer.PIIMode, config.Analyzer.PIIHashKey = mode, key
+	t.Cleanup(func() {
+		config.Analyzer.PIIMode, config.Analyzer.PIIHashKey = prevMode, prevKey
+	})
+}
+
+func TestNormalizePII(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   string
+		value string
+		want  string
+	}{
+		{"email is lowercased", PIIEmail, " John.Doe@Example.COM ", "john.doe@example.com"},
+		{"email needs an at sign", PIIEmail, "john.doe.example.com", ""},
+		{"international phone", PIIPhone, "+1 (415) 555-2671", "+14155552671"},
+		{"national phone", PIIPhone, "(415) 555-2671", "4155552671"},
+		{"compact phone", PIIPhone, "+14155552671", "+14155552671"},
+		{"phone with dots", PIIPhone, "+44.20.7946.0958", "+442079460958"},
+		{"too few digits", PIIPhone, "+1 555 26", ""},
+		{"too many digits", PIIPhone, "+1 234 567 890 123 456", ""},
+		{"iso date", PIIPhone, "2024-01-31", ""},
+		{"european date", PIIPhone, "31.01.2024", ""},
+		{"dotted quad", PIIPhone, "192.168.100.200", ""},
+		{"dotted quad with plus", PIIPhone, "+192.168.100.200", ""},
+		{"custom type is trimmed", "iban", " DE89 3704 0044 0532 0130 00 ", "DE89 3704 0044 0532 0130 00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizePII(tt.typ, tt.value); got != tt.want {
+				t.Errorf("normalizePII(%q, %q) = %q, want %q", tt.typ, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractPIIPhoneNumbers(t *testing.T) {
+	withPIIMode(t, config.PIIPlain, nil)
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"international", "Call +1 (415) 555-2671 today", []string{"+14155552671"}},
+		{"area code", "Call (415) 555-2671 today", []string{"4155552671"}},
+		{"uk number", "Office: +44 20 7946 0958", []string{"+442079460958"}},
+		{"ip address", "Server at 192.168.100.200 is down", nil},
+		{"version string", "Upgrade to version 10.2.3.4 now", nil},
+		{"amount", "Raised 1 000 000 dollars", nil},
+		{"date", "Published 2024-01-31", nil},
+		{"bare digits", "Order 4155552671 shipped", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range extractPII(nil, tt.text) {
+				if m.Type == PIIPhone {
+					got = append(got, m.Value)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("phones in %q = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractPIIDeduplicates(t *testing.T) {
+	withPIIMode(t, config.PIIPlain, nil)
+
+	matches := extractPII(nil, "Mail Jane@Example.com or jane@example.com")
+	if len(matches) != 1 {
+		t.Fatalf("extractPII returned %d matches, want 1: %+v", len(matches), matches)
+	}
+	if m := matches[0]; m.Type != PIIEmail || m.Value != "jane@example.com" || m.Count != 2 {
+		t.Errorf("extractPII = %+v, want jane@example.com found twice", m)
+	}
+}
+
+func TestRedactPII(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value string
+		want  string
+	}{
+		{PIIEmail, "jane@example.com", "j***@example.com"},
+		{PIIPhone, "+14155552671", "**********71"},
+		{"iban", "DE89370400440532013000", "D********************0"},
+		{"iban", "DE", "**"},
+	}
+	for _, tt := range tests {
+		if got := redactPII(tt.typ, tt.value); got != tt.want {
+			t.Errorf("redactPII(%q, %q) = %q, want %q", tt.typ, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestHashPII(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	withPIIMode(t, config.PIIHash, key)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("jane@example.com"))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got := storePIIValue(PIIEmail, "jane@example.com"); got != want {
+		t.Errorf("storePIIValue in hash mode = %q, want HMAC-SHA256 %q", got, want)
+	}
+
+	config.Analyzer.PIIHashKey = []byte("fedcba9876543210fedcba9876543210")
+	if got := hashPII("jane@example.com"); got == want {
+		t.Error("hashPII gave the same digest under a different key")
+	}
+}
+
+func TestRedactPIIText(t *testing.T) {
+	const text = "Contact jane@example.com or +1 (415) 555-2671 from 192.168.100.200"
+	const want = "Contact j***@example.com or **********71 from 192.168.100.200"
+
+	// Snapshot masking does not depend on how PII is stored in the results
+	for _, mode := range []string{config.PIIOff, config.PIIPlain, config.PIIRedact, config.PIIHash} {
+		t.Run(mode, func(t *testing.T) {
+			withPIIMode(t, mode, nil)
+			if got := redactPIIText(text); got != want {
+				t.Errorf("redactPIIText = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"golang.org/x/net/http/httpproxy"
+)
+
+/*
+proxyFunc returns the proxy selector for the analyzer's transport.
+
+A proxy in the URL's request profile takes precedence over the global
+ANALYZE_PROXY_URL, which in turn takes precedence over the standard
+HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables. HTTP, HTTPS and
+SOCKS5 proxies are supported; credentials are passed as URL user info and
+sent as Proxy-Authorization (HTTP) or in the SOCKS5 handshake.
+*/
+func proxyFunc(profile *models.RequestProfile) func(*http.Request) (*url.URL, error) {
+	var proxyURL, noProxy string
+	switch {
+	case profile != nil && profile.Proxy != nil && profile.Proxy.URL != "":
+		proxyURL = proxyWithAuth(profile.Proxy.URL, profile.Proxy.Username, profile.Proxy.Password)
+		noProxy = strings.Join(profile.Proxy.NoProxy, ",")
+	case config.Analyzer.ProxyURL != "":
+		proxyURL = config.Analyzer.ProxyURL
+		noProxy = config.Analyzer.NoProxy
+	default:
+		return http.ProxyFromEnvironment
+	}
+
+	selector := (&httpproxy.Config{
+		HTTPProxy:  proxyURL,
+		HTTPSProxy: proxyURL,
+		NoProxy:    noProxy,
+	}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return selector(req.URL)
+	}
+}
+
+// proxyWithAuth embeds username and password into rawURL unless it already carries credentials.
+func proxyWithAuth(rawURL, username, password string) string {
+	if username == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User != nil {
+		return rawURL
+	}
+	u.User = url.UserPassword(username, password)
+	return u.String()
+}
+
+// ValidateProxyURL checks that rawURL is an absolute http, https or socks5 proxy address.
+func ValidateProxyURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy address %q has no host", rawURL)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+)
+
+// maxHonoredCrawlDelay caps robots.txt Crawl-delay values so a hostile file cannot stall the workers.
+const maxHonoredCrawlDelay = 30 * time.Second
+
+/*
+errHostThrottled is returned for requests that could not start within
+RequestTimeout because of the host's politeness limits. Probes failing
+with it are recorded as skipped rather than as failures.
+*/
+var errHostThrottled = errors.New("throttled by per-host politeness limits")
+
+// hostState tracks the politeness state of a single host.
+type hostState struct {
+	slots        chan struct{} // Concurrency slots, one per in-flight request
+	next         time.Time     // Earliest start time of the next request
+	crawlDelay   time.Duration // Crawl-delay from robots.txt, if any
+	blockedUntil time.Time     // Set from Retry-After on 429/503 responses
+	lastUsed     time.Time     // Used to prune idle hosts
+}
+
+/*
+hostLimiter enforces per-host politeness for all outbound analyzer traffic:
+a cap on concurrent requests, a minimum delay between request starts
+(the larger of HOST_MIN_DELAY_MS and the host's robots.txt Crawl-delay),
+and a pause after a 429 or 503 response carrying Retry-After.
+
+A single limiter is shared by every worker, so the limits hold across
+concurrent analyses of pages on the same host.
+*/
+type hostLimiter struct {
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// hostLimits is the process-wide limiter used by every analyzer HTTP client.
+var hostLimits = &hostLimiter{hosts: map[string]*hostState{}}
+
+// state returns the state for host, creating it on first use.
+func (l *hostLimiter) state(host string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	s, ok := l.hosts[host]
+	if !ok {
+		l.prune(now)
+		concurrency := config.Analyzer.HostMaxConcurrency
+		if concurrency <= 0 {
+			concurrency = 1
+		}
+		s = &hostState{slots: make(chan struct{}, concurrency)}
+		l.hosts[host] = s
+	}
+	s.lastUsed = now
+	return s
+}
+
+// prune drops hosts idle for a while; the caller must hold l.mu.
+func (l *hostLimiter) prune(now time.Time) {
+	if len(l.hosts) < 1000 {
+		return
+	}
+	for host, s := range l.hosts {
+		if len(s.slots) == 0 && now.Sub(s.lastUsed) > 10*time.Minute && now.After(s.blockedUntil) {
+			delete(l.hosts, host)
+		}
+	}
+}
+
+/*
+acquire blocks until a request to host may start, or ctx is done. On
+success the returned function must be called once the request finishes.
+*/
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	s := l.state(host)
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() { <-s.slots }
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		start := s.next
+		if s.blockedUntil.After(start) {
+			start = s.blockedUntil
+		}
+		if !start.After(now) {
+			delay := config.Analyzer.HostMinDelay
+			if s.crawlDelay > delay {
+				delay = s.crawlDelay
+			}
+			s.next = now.Add(delay)
+			l.mu.Unlock()
+			return release, nil
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(start.Sub(now))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// setCrawlDelay records the robots.txt Crawl-delay for host, capped at maxHonoredCrawlDelay.
+func (l *hostLimiter) setCrawlDelay(host string, delay time.Duration) {
+	if delay > maxHonoredCrawlDelay {
+		delay = maxHonoredCrawlDelay
+	}
+	s := l.state(host)
+	l.mu.Lock()
+	s.crawlDelay = delay
+	l.mu.Unlock()
+}
+
+// backoff pauses all requests to host for d, capped at config.Analyzer.RetryAfterMax.
+func (l *hostLimiter) backoff(host string, d time.Duration) {
+	if d > config.Analyzer.RetryAfterMax {
+		d = config.Analyzer.RetryAfterMax
+	}
+	s := l.state(host)
+	l.mu.Lock()
+	if until := time.Now().Add(d); until.After(s.blockedUntil) {
+		s.blockedUntil = until
+	}
+	l.mu.Unlock()
+}
+
+/*
+rateLimitTransport applies hostLimits to every request. The concurrency
+slot is held until the response body is closed, since the connection is
+in use until then.
+
+The politeness wait is bounded by RequestTimeout and does not count
+against the request itself: the per-request deadline starts once the
+request is allowed to go out, and lasts until the body is closed.
+*/
+type rateLimitTransport struct {
+	base http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Host)
+	waitCtx, cancelWait := context.WithTimeout(req.Context(), config.Analyzer.RequestTimeout)
+	release, err := hostLimits.acquire(waitCtx, host)
+	cancelWait()
+	if err != nil {
+		if req.Context().Err() == nil {
+			return nil, errHostThrottled
+		}
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), config.Analyzer.RequestTimeout)
+	done := func() {
+		cancel()
+		release()
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			hostLimits.backoff(host, d)
+		}
+	}
+
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: done}
+	return resp, nil
+}
+
+// CloseIdleConnections releases the pooled connections of the wrapped transport.
+func (t *rateLimitTransport) CloseIdleConnections() {
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// releaseOnClose calls release exactly once when the body is closed.
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// parseRetryAfter parses a Retry-After value given either in seconds or as an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(value); err == nil && sec >= 0 {
+		return time.Duration(sec) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
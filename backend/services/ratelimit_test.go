@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+)
+
+// withAnalyzerConfig applies change to config.Analyzer for the duration of a test.
+func withAnalyzerConfig(t *testing.T, change func(*config.AnalyzerConfig)) {
+	t.Helper()
+	prev := config.Analyzer
+	change(&config.Analyzer)
+	t.Cleanup(func() { config.Analyzer = prev })
+}
+
+func newTestLimiter() *hostLimiter {
+	return &hostLimiter{hosts: map[string]*hostState{}}
+}
+
+// acquireWithin acquires a slot for host, failing the test unless it happens within limit.
+func acquireWithin(t *testing.T, l *hostLimiter, host string, limit time.Duration) (release func(), waited time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), limit)
+	defer cancel()
+	start := time.Now()
+	release, err := l.acquire(ctx, host)
+	if err != nil {
+		t.Fatalf("acquire(%s) failed after %v: %v", host, time.Since(start), err)
+	}
+	return release, time.Since(start)
+}
+
+func TestHostLimiterSlots(t *testing.T) {
+	withAnalyzerConfig(t, func(c *config.AnalyzerConfig) {
+		c.HostMaxConcurrency = 2
+		c.HostMinDelay = 0
+	})
+	l := newTestLimiter()
+
+	release1, _ := acquireWithin(t, l, "example.com", time.Second)
+	release2, _ := acquireWithin(t, l, "example.com", time.Second)
+
+	// Both slots are taken, so a third request must wait
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx, "example.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("third acquire = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Slots are per host
+	releaseOther, _ := acquireWithin(t, l, "other.org", time.Second)
+	releaseOther()
+
+	release1()
+	release3, _ := acquireWithin(t, l, "example.com", time.Second)
+	release2()
+	release3()
+}
+
+func TestHostLimiterDelays(t *testing.T) {
+	tests := []struct {
+		name       string
+		minDelay   time.Duration
+		crawlDelay time.Duration
+		backoff    time.Duration
+		want       time.Duration // Minimum wait for the second request
+	}{
+		{"no delay", 0, 0, 0, 0},
+		{"min delay", 150 * time.Millisecond, 0, 0, 150 * time.Millisecond},
+		{"crawl-delay above min delay", 50 * time.Millisecond, 200 * time.Millisecond, 0, 200 * time.Millisecond},
+		{"min delay above crawl-delay", 200 * time.Millisecond, 50 * time.Millisecond, 0, 200 * time.Millisecond},
+		{"retry-after backoff", 0, 0, 250 * time.Millisecond, 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withAnalyzerConfig(t, func(c *config.AnalyzerConfig) {
+				c.HostMaxConcurrency = 4
+				c.HostMinDelay = tt.minDelay
+				c.RetryAfterMax = time.Minute
+			})
+			l := newTestLimiter()
+			if tt.crawlDelay > 0 {
+				l.setCrawlDelay("example.com", tt.crawlDelay)
+			}
+
+			release, _ := acquireWithin(t, l, "example.com", time.Second)
+			release()
+			if tt.backoff > 0 {
+				l.backoff("example.com", tt.backoff)
+			}
+
+			// Allow for timer granularity, but the wait must not be skipped
+			release, waited := acquireWithin(t, l, "example.com", 2*time.Second)
+			release()
+			if waited < tt.want-10*time.Millisecond {
+				t.Errorf("second request waited %v, want at least %v", waited, tt.want)
+			}
+			if tt.want == 0 && waited > 100*time.Millisecond {
+				t.Errorf("second request waited %v, want no wait", waited)
+			}
+		})
+	}
+}
+
+func TestHostLimiterCaps(t *testing.T) {
+	withAnalyzerConfig(t, func(c *config.AnalyzerConfig) {
+		c.HostMaxConcurrency = 1
+		c.RetryAfterMax = 2 * time.Second
+	})
+	l := newTestLimiter()
+
+	l.setCrawlDelay("example.com", time.Hour)
+	if got := l.state("example.com").crawlDelay; got != maxHonoredCrawlDelay {
+		t.Errorf("crawl-delay of an hour recorded as %v, want the %v cap", got, maxHonoredCrawlDelay)
+	}
+
+	l.backoff("example.com", time.Hour)
+	if until := time.Until(l.state("example.com").blockedUntil); until > 2*time.Second {
+		t.Errorf("Retry-After of an hour blocks the host for %v, want at most RetryAfterMax (2s)", until)
+	}
+
+	// A shorter Retry-After does not shorten an existing pause
+	before := l.state("example.com").blockedUntil
+	l.backoff("example.com", time.Millisecond)
+	if after := l.state("example.com").blockedUntil; after.Before(before) {
+		t.Errorf("a shorter Retry-After moved blockedUntil back from %v to %v", before, after)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"120", 120 * time.Second, true},
+		{" 0 ", 0, true},
+		{"", 0, false},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{future, 90 * time.Second, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) ok = %v, want %v", tt.value, ok, tt.wantOK)
+			continue
+		}
+		// HTTP dates have a one-second resolution
+		if diff := got - tt.want; diff < -2*time.Second || diff > 2*time.Second {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	withAnalyzerConfig(t, func(c *config.AnalyzerConfig) {
+		c.HostMaxConcurrency = 4
+		c.HostMinDelay = 0
+		c.RequestTimeout = 200 * time.Millisecond
+		c.RetryAfterMax = time.Minute
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/busy" {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	host := strings.ToLower(mustParseURL(t, srv.URL).Host)
+	client := &http.Client{Transport: &rateLimitTransport{base: http.DefaultTransport}}
+
+	resp, err := client.Get(srv.URL + "/page")
+	if err != nil {
+		t.Fatalf("GET /page: %v", err)
+	}
+	resp.Body.Close()
+	if n := len(hostLimits.state(host).slots); n != 0 {
+		t.Errorf("%d slots still held after the body was closed", n)
+	}
+
+	// A 429 with Retry-After pauses the host beyond the request timeout,
+	// so the next request is reported as throttled instead of waiting
+	resp, err = client.Get(srv.URL + "/busy")
+	if err != nil {
+		t.Fatalf("GET /busy: %v", err)
+	}
+	resp.Body.Close()
+	_, err = client.Get(srv.URL + "/page")
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || !errors.Is(urlErr.Err, errHostThrottled) {
+		t.Errorf("GET after Retry-After = %v, want errHostThrottled", err)
+	}
+
+	hostLimits.mu.Lock()
+	delete(hostLimits.hosts, host)
+	hostLimits.mu.Unlock()
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+)
+
+// ErrProfileKeyMissing is returned when a request profile is used without PROFILE_ENCRYPTION_KEY.
+var ErrProfileKeyMissing = errors.New("request profiles require PROFILE_ENCRYPTION_KEY to be set")
+
+/*
+ValidateRequestProfile checks a user-supplied request profile before it is
+stored. Header names must be valid tokens and the auth type, if any, must
+be "basic" (with a username) or "bearer" (with a token). A proxy, if set,
+must be an http, https or socks5 URL.
+*/
+func ValidateRequestProfile(p *models.RequestProfile) error {
+	for name := range p.Headers {
+		if !validHeaderName(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	}
+	for name := range p.Cookies {
+		if name == "" || strings.ContainsAny(name, "=; \t\r\n") {
+			return fmt.Errorf("invalid cookie name %q", name)
+		}
+	}
+	if p.Auth != nil {
+		switch strings.ToLower(p.Auth.Type) {
+		case "basic":
+			if p.Auth.Username == "" {
+				return errors.New("basic auth requires a username")
+			}
+		case "bearer":
+			if p.Auth.Token == "" {
+				return errors.New("bearer auth requires a token")
+			}
+		default:
+			return fmt.Errorf("unsupported auth type %q", p.Auth.Type)
+		}
+	}
+	if p.Proxy != nil {
+		if err := ValidateProxyURL(p.Proxy.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+EncryptRequestProfile serializes a request profile and seals it with
+AES-256-GCM using config.Analyzer.ProfileKey. The random nonce is
+prepended to the ciphertext.
+*/
+func EncryptRequestProfile(p *models.RequestProfile) ([]byte, error) {
+	gcm, err := profileCipher()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+/*
+DecryptRequestProfile opens a profile sealed by EncryptRequestProfile.
+An empty input yields a nil profile and no error.
+*/
+func DecryptRequestProfile(data []byte) (*models.RequestProfile, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	gcm, err := profileCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("request profile is corrupted")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errors.New("request profile cannot be decrypted with the configured key")
+	}
+
+	var p models.RequestProfile
+	if err := json.Unmarshal(plain, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// profileCipher builds the AEAD used to seal request profiles.
+func profileCipher() (cipher.AEAD, error) {
+	if len(config.Analyzer.ProfileKey) == 0 {
+		return nil, ErrProfileKeyMissing
+	}
+	block, err := aes.NewCipher(config.Analyzer.ProfileKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+/*
+profileTransport decorates every outbound request with the analyzer's
+User-Agent and the URL's request profile.
+
+Credentials, cookies and custom headers are only attached to requests for
+the analyzed page's host so that they never leak to third-party links or
+subresources, and only over HTTPS or the page's own scheme so that an
+https page never sends them in clear text to an http:// link on the same
+host; the User-Agent and Referer are sent everywhere.
+*/
+type profileTransport struct {
+	base    http.RoundTripper
+	profile *models.RequestProfile
+	host    string // Host of the analyzed page
+	scheme  string // Scheme of the analyzed page
+}
+
+func (t *profileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+
+	userAgent := config.Analyzer.UserAgent
+	if t.profile != nil && t.profile.UserAgent != "" {
+		userAgent = t.profile.UserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	if p := t.profile; p != nil {
+		if p.Referer != "" {
+			req.Header.Set("Referer", p.Referer)
+		}
+		if t.sendsCredentials(req.URL) {
+			for name, value := range p.Headers {
+				req.Header.Set(name, value)
+			}
+			for name, value := range p.Cookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			if p.Auth != nil {
+				switch strings.ToLower(p.Auth.Type) {
+				case "basic":
+					req.SetBasicAuth(p.Auth.Username, p.Auth.Password)
+				case "bearer":
+					req.Header.Set("Authorization", "Bearer "+p.Auth.Token)
+				}
+			}
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// sendsCredentials reports whether the profile's credentials may be attached to a request for target.
+func (t *profileTransport) sendsCredentials(target *url.URL) bool {
+	if !strings.EqualFold(target.Hostname(), t.host) {
+		return false
+	}
+	scheme := strings.ToLower(target.Scheme)
+	return scheme == "https" || scheme == t.scheme
+}
+
+// CloseIdleConnections releases the pooled connections of the wrapped transport.
+func (t *profileTransport) CloseIdleConnections() {
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// validHeaderName reports whether name is a valid HTTP header field name (RFC 7230 token).
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c >= 0x7f || c <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return true
+}
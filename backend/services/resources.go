@@ -0,0 +1,231 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"golang.org/x/net/html"
+)
+
+// Mixed content classes as defined by the W3C Mixed Content specification.
+const (
+	MixedContentActive  = "active"  // Blockable: scripts, styles, frames, form targets...
+	MixedContentPassive = "passive" // Optionally-blockable: images, audio and video
+)
+
+/*
+collectResourceRefs returns the subresource references declared on a single
+element node. Relative references are resolved against base.
+
+Only tags that make the browser fetch something on page load (or submit
+somewhere, in the case of forms) are considered. Empty, data: and
+javascript: references are skipped because they never hit the network.
+*/
+func collectResourceRefs(n *html.Node, tag, base string) []models.Resource {
+	var refs []models.Resource
+	add := func(attr, typ, val string) {
+		val = strings.TrimSpace(val)
+		if val == "" || hasSchemePrefix(val, "data:", "javascript:", "about:", "blob:", "mailto:", "tel:") {
+			return
+		}
+		abs := resolveURL(base, val)
+		refs = append(refs, models.Resource{
+			URL:       abs,
+			Host:      extractHost(abs),
+			Tag:       tag,
+			Attribute: attr,
+			Type:      typ,
+			Size:      -1,
+		})
+	}
+
+	switch tag {
+	case "script":
+		add("src", "script", attrValue(n, "src"))
+
+	case "link":
+		if typ := linkResourceType(n); typ != "" {
+			add("href", typ, attrValue(n, "href"))
+		}
+
+	case "img":
+		add("src", "image", attrValue(n, "src"))
+		for _, candidate := range parseSrcset(attrValue(n, "srcset")) {
+			add("srcset", "image", candidate)
+		}
+
+	case "iframe", "frame":
+		add("src", "iframe", attrValue(n, "src"))
+
+	case "form":
+		add("action", "form", attrValue(n, "action"))
+
+	case "video", "audio":
+		add("src", "media", attrValue(n, "src"))
+		if tag == "video" {
+			add("poster", "image", attrValue(n, "poster"))
+		}
+
+	case "source":
+		typ := "media"
+		if n.Parent != nil && strings.EqualFold(n.Parent.Data, "picture") {
+			typ = "image"
+		}
+		add("src", typ, attrValue(n, "src"))
+		for _, candidate := range parseSrcset(attrValue(n, "srcset")) {
+			add("srcset", typ, candidate)
+		}
+
+	case "track":
+		add("src", "media", attrValue(n, "src"))
+
+	case "embed":
+		add("src", "object", attrValue(n, "src"))
+
+	case "object":
+		add("data", "object", attrValue(n, "data"))
+	}
+
+	return refs
+}
+
+/*
+linkResourceType maps a <link> element to a resource type based on its rel
+(and, for preloads, its "as") attribute. Links that do not cause a fetch on
+page load, such as canonical or alternate, return an empty string.
+*/
+func linkResourceType(n *html.Node) string {
+	for _, rel := range strings.Fields(strings.ToLower(attrValue(n, "rel"))) {
+		switch rel {
+		case "stylesheet":
+			return "stylesheet"
+		case "icon", "apple-touch-icon", "apple-touch-icon-precomposed", "mask-icon":
+			return "icon"
+		case "manifest":
+			return "manifest"
+		case "modulepreload":
+			return "script"
+		case "preload":
+			switch strings.ToLower(attrValue(n, "as")) {
+			case "script", "worker":
+				return "script"
+			case "style":
+				return "stylesheet"
+			case "font":
+				return "font"
+			case "image":
+				return "image"
+			case "audio", "video", "track":
+				return "media"
+			case "document":
+				return "iframe"
+			}
+		}
+	}
+	return ""
+}
+
+/*
+classifyMixedContent reports whether a resource loaded from an HTTPS page is
+active or passive mixed content. It returns an empty string when the page
+itself is not served over HTTPS or the resource is not plain HTTP.
+
+Images and audio/video are passive (optionally-blockable) content; everything
+else — scripts, styles, fonts, frames, plugins and form targets — can alter
+the page or leak data and is therefore treated as active.
+*/
+func classifyMixedContent(pageScheme string, r models.Resource) string {
+	if !strings.EqualFold(pageScheme, "https") || !hasSchemePrefix(r.URL, "http:") {
+		return ""
+	}
+	switch r.Type {
+	case "image", "icon", "media":
+		return MixedContentPassive
+	default:
+		return MixedContentActive
+	}
+}
+
+/*
+parseSrcset extracts the candidate URLs from a srcset attribute value such as
+"small.jpg 480w, large.jpg 1080w". Width and density descriptors are dropped.
+
+Candidates are split as in the HTML parsing algorithm rather than on every
+comma: a URL runs up to the next whitespace (trailing commas end it early)
+and its descriptors run up to the next comma outside parentheses, so data:
+URIs and URLs containing commas are kept intact.
+*/
+func parseSrcset(srcset string) []string {
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+	}
+
+	var urls []string
+	i := 0
+	for i < len(srcset) {
+		// Skip separators before the next candidate
+		for i < len(srcset) && (isSpace(srcset[i]) || srcset[i] == ',') {
+			i++
+		}
+		start := i
+		for i < len(srcset) && !isSpace(srcset[i]) {
+			i++
+		}
+		candidate := srcset[start:i]
+		if candidate == "" {
+			break
+		}
+
+		// A URL ending in commas has no descriptors
+		if trimmed := strings.TrimRight(candidate, ","); trimmed != candidate {
+			if trimmed != "" {
+				urls = append(urls, trimmed)
+			}
+			continue
+		}
+		urls = append(urls, candidate)
+		i = skipSrcsetDescriptors(srcset, i)
+	}
+	return urls
+}
+
+// skipSrcsetDescriptors returns the index after the comma ending the descriptors that start at i.
+func skipSrcsetDescriptors(srcset string, i int) int {
+	depth := 0
+	for ; i < len(srcset); i++ {
+		switch srcset[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// attrValue returns the value of the named attribute on n, or "" if absent.
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// hasSchemePrefix reports whether raw starts with any of the given schemes, ignoring case.
+func hasSchemePrefix(raw string, schemes ...string) bool {
+	lower := strings.ToLower(raw)
+	for _, scheme := range schemes {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
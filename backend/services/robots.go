@@ -0,0 +1,354 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+)
+
+// Robots verdicts recorded on each analysis.
+const (
+	RobotsAllowed    = "allowed"
+	RobotsDisallowed = "disallowed"
+	RobotsNotChecked = "not_checked"
+)
+
+// robotsCacheTTL is how long a fetched robots.txt is reused for the same host.
+const robotsCacheTTL = time.Hour
+
+// robotsErrorTTL is how long a failure to reach robots.txt is remembered before retrying.
+const robotsErrorTTL = time.Minute
+
+// robotsMaxBytes caps the robots.txt body size, as recommended by RFC 9309.
+const robotsMaxBytes = 500 << 10
+
+// robotsRule is a single Allow or Disallow line.
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsGroup holds the rules shared by one or more user-agent lines.
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+/*
+robotsRules is a parsed robots.txt file.
+
+allowAll and disallowAll short-circuit evaluation for the special cases
+defined by RFC 9309: a missing file (4xx) allows everything, while a
+server error (5xx) disallows everything. Network errors produce no rules
+at all; they are reported to the caller instead (see robotsFor).
+*/
+type robotsRules struct {
+	groups      []robotsGroup
+	sitemaps    []string
+	allowAll    bool
+	disallowAll bool
+}
+
+// robotsCacheEntry is a cached robots.txt, or the error fetching it, together with the time it was fetched.
+type robotsCacheEntry struct {
+	rules     *robotsRules
+	err       error
+	fetchedAt time.Time
+}
+
+var (
+	// robotsCache maps "scheme://host" to its most recently fetched robots.txt
+	robotsCache = map[string]robotsCacheEntry{}
+
+	// robotsMu guards robotsCache
+	robotsMu sync.Mutex
+)
+
+/*
+parseRobots parses a robots.txt body into groups of rules.
+
+Consecutive user-agent lines start a single group; rules that appear before
+any user-agent line are ignored. Sitemap lines are collected regardless of
+where they appear. Unknown directives and comments are skipped.
+*/
+func parseRobots(r io.Reader) *robotsRules {
+	rules := &robotsRules{}
+	var current *robotsGroup
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || !lastWasAgent {
+				rules.groups = append(rules.groups, robotsGroup{})
+				current = &rules.groups[len(rules.groups)-1]
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+
+		case "allow", "disallow":
+			// An empty Disallow means "allow everything" and adds no rule
+			if current != nil && value != "" {
+				current.rules = append(current.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+
+		case "crawl-delay":
+			if current != nil {
+				if sec, err := strconv.ParseFloat(value, 64); err == nil && sec > 0 {
+					current.crawlDelay = time.Duration(sec * float64(time.Second))
+				}
+			}
+
+		case "sitemap":
+			if value != "" {
+				rules.sitemaps = append(rules.sitemaps, value)
+			}
+		}
+		lastWasAgent = false
+	}
+	return rules
+}
+
+/*
+group returns the group that applies to userAgent: the first one listing
+its product token (compared case-insensitively, as required by RFC 9309),
+or the "*" group if none does. A group for "url" therefore does not apply
+to "url-analyzer". It returns nil when no group applies.
+*/
+func (rr *robotsRules) group(userAgent string) *robotsGroup {
+	token := productToken(userAgent)
+	var wildcard *robotsGroup
+	for i := range rr.groups {
+		g := &rr.groups[i]
+		for _, agent := range g.agents {
+			if agent == "*" {
+				if wildcard == nil {
+					wildcard = g
+				}
+				continue
+			}
+			if productToken(agent) == token {
+				return g
+			}
+		}
+	}
+	return wildcard
+}
+
+// productToken returns the lowercased product token of a user agent, without version or comments.
+func productToken(userAgent string) string {
+	token := strings.ToLower(strings.TrimSpace(userAgent))
+	if i := strings.IndexAny(token, "/ \t("); i >= 0 {
+		token = token[:i]
+	}
+	return token
+}
+
+/*
+Allowed reports whether userAgent may fetch the given path (including any
+query string). The longest matching rule wins and Allow wins ties.
+*/
+func (rr *robotsRules) Allowed(userAgent, path string) bool {
+	if rr.allowAll {
+		return true
+	}
+	if rr.disallowAll {
+		return false
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	g := rr.group(userAgent)
+	if g == nil {
+		return true
+	}
+
+	allowed, matchLen := true, -1
+	for _, rule := range g.rules {
+		if !robotsPatternMatch(rule.pattern, path) {
+			continue
+		}
+		n := len(rule.pattern)
+		if n > matchLen || (n == matchLen && rule.allow) {
+			allowed, matchLen = rule.allow, n
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the Crawl-delay that applies to userAgent, or 0 if none is set.
+func (rr *robotsRules) CrawlDelay(userAgent string) time.Duration {
+	if rr.allowAll || rr.disallowAll {
+		return 0
+	}
+	if g := rr.group(userAgent); g != nil {
+		return g.crawlDelay
+	}
+	return 0
+}
+
+/*
+robotsPatternMatch matches a robots.txt path pattern against path.
+"*" matches any sequence of characters and a trailing "$" anchors the
+pattern to the end of the path; otherwise patterns are prefix matches.
+*/
+func robotsPatternMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+
+	// Match the middle segments greedily left to right; the last segment
+	// must end the path when anchored, otherwise it may appear anywhere.
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	last := parts[len(parts)-1]
+	if anchored {
+		return strings.HasSuffix(rest, last)
+	}
+	return strings.Contains(rest, last)
+}
+
+/*
+robotsFor returns the robots.txt rules for the host of rawURL, fetching and
+caching them per scheme and host. The host's Crawl-delay is handed to the
+per-host limiter so later requests are spaced accordingly.
+
+When robots.txt cannot be fetched at all (DNS failure, refused connection,
+timeout), the network error is returned so the caller can report the host
+as unreachable. It is remembered for robotsErrorTTL only, so a transient
+failure does not block the host for the full robotsCacheTTL.
+*/
+func robotsFor(ctx context.Context, client *http.Client, rawURL string) (*robotsRules, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return &robotsRules{allowAll: true}, nil
+	}
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+
+	robotsMu.Lock()
+	entry, ok := robotsCache[key]
+	robotsMu.Unlock()
+	if ok && entry.err == nil && time.Since(entry.fetchedAt) < robotsCacheTTL {
+		return entry.rules, nil
+	}
+	if ok && entry.err != nil && time.Since(entry.fetchedAt) < robotsErrorTTL {
+		return nil, entry.err
+	}
+
+	rules, err := fetchRobots(ctx, client, key+"/robots.txt")
+	if err != nil {
+		// Cancellation and throttling say nothing about the host
+		if ctx.Err() == nil && !errors.Is(err, errHostThrottled) {
+			robotsMu.Lock()
+			robotsCache[key] = robotsCacheEntry{err: err, fetchedAt: time.Now()}
+			robotsMu.Unlock()
+		}
+		return nil, err
+	}
+	hostLimits.setCrawlDelay(strings.ToLower(u.Host), rules.CrawlDelay(config.Analyzer.RobotsUserAgent))
+
+	robotsMu.Lock()
+	robotsCache[key] = robotsCacheEntry{rules: rules, fetchedAt: time.Now()}
+	robotsMu.Unlock()
+	return rules, nil
+}
+
+// fetchRobots downloads and parses a robots.txt file, applying RFC 9309 error handling to HTTP statuses.
+func fetchRobots(ctx context.Context, client *http.Client, robotsURL string) (*robotsRules, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return &robotsRules{disallowAll: true}, nil
+	case resp.StatusCode >= 400:
+		return &robotsRules{allowAll: true}, nil
+	}
+	return parseRobots(io.LimitReader(resp.Body, robotsMaxBytes)), nil
+}
+
+/*
+robotsCheck evaluates rawURL against its host's robots.txt under the
+configured policy. It returns the verdict to record and whether the
+request may proceed; with the "ignore" policy robots.txt is never fetched.
+When robots.txt cannot be reached, the verdict is not_checked and err
+holds the network error; only the "obey" policy then holds the request
+back, while "report" lets it proceed. Requests throttled by the per-host
+limits never proceed.
+*/
+func robotsCheck(ctx context.Context, client *http.Client, rawURL string) (verdict string, proceed bool, err error) {
+	if config.Analyzer.RobotsPolicy == config.RobotsIgnore {
+		return RobotsNotChecked, true, nil
+	}
+
+	rules, err := robotsFor(ctx, client, rawURL)
+	if err != nil {
+		proceed = config.Analyzer.RobotsPolicy == config.RobotsReport && !errors.Is(err, errHostThrottled)
+		return RobotsNotChecked, proceed, err
+	}
+	verdict = RobotsAllowed
+	if !rules.Allowed(config.Analyzer.RobotsUserAgent, requestPath(rawURL)) {
+		verdict = RobotsDisallowed
+	}
+	return verdict, verdict == RobotsAllowed || config.Analyzer.RobotsPolicy == config.RobotsReport, nil
+}
+
+// requestPath returns the path and query of rawURL as matched by robots.txt rules.
+func requestPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "/"
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path
+}
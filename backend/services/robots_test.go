@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+)
+
+const testRobotsTxt = `# Example robots.txt
+Sitemap: https://example.com/sitemap.xml
+
+User-agent: *
+Disallow: /private/
+Allow: /private/public-page
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: url-analyzer
+User-agent: OtherBot
+Disallow: /no-analyzer
+Allow: /no-analyzer/ok
+Disallow:
+
+User-agent: url
+Disallow: /
+
+Sitemap: https://example.com/news-sitemap.xml
+`
+
+func TestParseRobots(t *testing.T) {
+	rules := parseRobots(strings.NewReader(testRobotsTxt))
+
+	wantSitemaps := []string{"https://example.com/sitemap.xml", "https://example.com/news-sitemap.xml"}
+	if !reflect.DeepEqual(rules.sitemaps, wantSitemaps) {
+		t.Errorf("sitemaps = %q, want %q", rules.sitemaps, wantSitemaps)
+	}
+	if len(rules.groups) != 3 {
+		t.Fatalf("parsed %d groups, want 3", len(rules.groups))
+	}
+	if agents := rules.groups[1].agents; !reflect.DeepEqual(agents, []string{"url-analyzer", "otherbot"}) {
+		t.Errorf("second group agents = %q, want consecutive user-agent lines in one group", agents)
+	}
+	if n := len(rules.groups[1].rules); n != 2 {
+		t.Errorf("second group has %d rules, want 2 (an empty Disallow adds none)", n)
+	}
+	if delay := rules.CrawlDelay("SomeBot/1.0"); delay != 2*time.Second {
+		t.Errorf("CrawlDelay(SomeBot) = %v, want 2s", delay)
+	}
+	if delay := rules.CrawlDelay("url-analyzer"); delay != 0 {
+		t.Errorf("CrawlDelay(url-analyzer) = %v, want 0", delay)
+	}
+}
+
+func TestParseRobotsIgnoresRulesBeforeUserAgent(t *testing.T) {
+	rules := parseRobots(strings.NewReader("Disallow: /\nUser-agent: *\nDisallow: /tmp\n"))
+	if !rules.Allowed("AnyBot", "/page") {
+		t.Error("a rule before any user-agent line should be ignored")
+	}
+	if rules.Allowed("AnyBot", "/tmp/file") {
+		t.Error("/tmp/file should be disallowed")
+	}
+}
+
+func TestRobotsAllowed(t *testing.T) {
+	rules := parseRobots(strings.NewReader(testRobotsTxt))
+	tests := []struct {
+		name      string
+		userAgent string
+		path      string
+		want      bool
+	}{
+		{"wildcard group allows", "SomeBot", "/index.html", true},
+		{"wildcard group disallows", "SomeBot", "/private/data", false},
+		{"longest match wins", "SomeBot", "/private/public-page", true},
+		{"anchored pattern", "SomeBot", "/files/report.pdf", false},
+		{"anchored pattern needs end", "SomeBot", "/files/report.pdf?download=1", true},
+		{"robots.txt is always allowed", "url", "/robots.txt", true},
+		{"specific group replaces wildcard", "url-analyzer", "/private/data", true},
+		{"specific group disallows", "url-analyzer", "/no-analyzer/page", false},
+		{"specific group allows", "url-analyzer", "/no-analyzer/ok", true},
+		{"version is ignored", "url-analyzer/2.1 (+https://example.com)", "/no-analyzer/page", false},
+		{"token is case-insensitive", "URL-Analyzer", "/no-analyzer/page", false},
+		{"any agent of a group", "OtherBot", "/no-analyzer/page", false},
+		{"full token match", "url", "/index.html", false},
+		{"no prefix match", "urlbot", "/index.html", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.Allowed(tt.userAgent, tt.path); got != tt.want {
+				t.Errorf("Allowed(%q, %q) = %v, want %v", tt.userAgent, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRobotsAllowedStatusRules(t *testing.T) {
+	if !(&robotsRules{allowAll: true}).Allowed("AnyBot", "/private") {
+		t.Error("allowAll (4xx robots.txt) should allow everything")
+	}
+	if (&robotsRules{disallowAll: true}).Allowed("AnyBot", "/") {
+		t.Error("disallowAll (5xx robots.txt) should disallow everything")
+	}
+	if !(&robotsRules{}).Allowed("AnyBot", "/anything") {
+		t.Error("an empty robots.txt should allow everything")
+	}
+}
+
+func TestRobotsPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/", "/anything", true},
+		{"/fish", "/fish", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish.asp", false},
+		{"/fish/", "/fish", false},
+		{"/*.php", "/index.php", true},
+		{"/*.php", "/folder/filename.php?parameters", true},
+		{"/*.php$", "/filename.php", true},
+		{"/*.php$", "/filename.php?parameters", false},
+		{"/fish*.php", "/fishheads/catfish.php?parameters", true},
+		{"/fish*.php", "/Fish.PHP", false},
+		{"/a*b*c", "/a-x-b-y-c", true},
+		{"/a*b*c", "/a-x-c-y-b", false},
+		{"/exact$", "/exact", true},
+		{"/exact$", "/exact/", false},
+	}
+	for _, tt := range tests {
+		if got := robotsPatternMatch(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("robotsPatternMatch(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestProductToken(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"url-analyzer", "url-analyzer"},
+		{"URL-Analyzer/1.0", "url-analyzer"},
+		{"Mozilla/5.0 (compatible; url-analyzer/1.0)", "mozilla"},
+		{"  Googlebot (+http://www.google.com/bot.html)", "googlebot"},
+		{"*", "*"},
+	}
+	for _, tt := range tests {
+		if got := productToken(tt.userAgent); got != tt.want {
+			t.Errorf("productToken(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsCheckUnreachable(t *testing.T) {
+	// A closed server refuses connections, so its robots.txt cannot be fetched
+	srv := httptest.NewServer(http.NotFoundHandler())
+	client := srv.Client()
+	srv.Close()
+
+	prev := config.Analyzer.RobotsPolicy
+	t.Cleanup(func() { config.Analyzer.RobotsPolicy = prev })
+
+	tests := []struct {
+		policy  string
+		proceed bool
+	}{
+		{config.RobotsObey, false},
+		{config.RobotsReport, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			config.Analyzer.RobotsPolicy = tt.policy
+			verdict, proceed, err := robotsCheck(context.Background(), client, srv.URL+"/page")
+			if err == nil {
+				t.Fatal("robotsCheck returned no error for an unreachable robots.txt")
+			}
+			if verdict != RobotsNotChecked || proceed != tt.proceed {
+				t.Errorf("robotsCheck = %q, proceed %v; want %q, proceed %v", verdict, proceed, RobotsNotChecked, tt.proceed)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/DMequanint/url-analyzer-pro/pkg/websockethub"
+	"github.com/robfig/cron/v3"
+)
+
+// minScheduleInterval is the shortest fixed interval accepted for recurring analyses.
+const minScheduleInterval = time.Minute
+
+// ScheduleRequest is the user-supplied schedule of a URL: either a fixed interval or a cron expression.
+type ScheduleRequest struct {
+	Interval int    `json:"interval"` // Seconds between runs
+	Cron     string `json:"cron"`     // Standard 5-field cron expression or descriptor such as @daily
+}
+
+/*
+ApplySchedule validates req and stores it on u, computing the next run
+from now. Exactly one of Interval or Cron must be set. The schedule is
+(re)activated if it was paused.
+*/
+func ApplySchedule(u *models.URL, req ScheduleRequest) error {
+	switch {
+	case req.Interval < 0:
+		return errors.New("interval must be positive")
+	case req.Interval != 0 && req.Cron != "":
+		return errors.New("set either interval or cron, not both")
+	case req.Cron != "":
+		if _, err := cron.ParseStandard(req.Cron); err != nil {
+			return errors.New("invalid cron expression: " + err.Error())
+		}
+	case time.Duration(req.Interval)*time.Second < minScheduleInterval:
+		return errors.New("interval must be at least 60 seconds")
+	}
+
+	u.ScheduleInterval = req.Interval
+	u.ScheduleCron = req.Cron
+	u.SchedulePaused = false
+	next, err := NextRun(u, time.Now())
+	if err != nil {
+		return err
+	}
+	u.NextRunAt = &next
+	return nil
+}
+
+// ClearSchedule removes any recurring schedule from u.
+func ClearSchedule(u *models.URL) {
+	u.ScheduleInterval = 0
+	u.ScheduleCron = ""
+	u.SchedulePaused = false
+	u.NextRunAt = nil
+}
+
+// HasSchedule reports whether u has a recurring schedule, paused or not.
+func HasSchedule(u *models.URL) bool {
+	return u.ScheduleInterval > 0 || u.ScheduleCron != ""
+}
+
+// NextRun returns the first scheduled run of u strictly after from.
+func NextRun(u *models.URL, from time.Time) (time.Time, error) {
+	if u.ScheduleCron != "" {
+		schedule, err := cron.ParseStandard(u.ScheduleCron)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return schedule.Next(from), nil
+	}
+	if u.ScheduleInterval > 0 {
+		return from.Add(time.Duration(u.ScheduleInterval) * time.Second), nil
+	}
+	return time.Time{}, errors.New("URL has no schedule")
+}
+
+/*
+RunScheduler requeues URLs whose schedule is due, checking every tick.
+
+A due URL is skipped while it is still queued or running; otherwise it
+is marked "queued" for the analysis workers (its latest results stay
+visible until the new run completes) and its next run is computed from
+the current time, so missed runs (e.g. while the server was down) are
+not replayed.
+*/
+func RunScheduler(tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		var due []models.URL
+		err := config.DB.
+			Where("schedule_paused = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", false, now).
+			Where("status NOT IN ?", []string{"queued", "running"}).
+			Find(&due).Error
+		if err != nil {
+			log.Printf("Scheduler query failed: %v", err)
+			continue
+		}
+
+		for i := range due {
+			u := &due[i]
+			next, err := NextRun(u, now)
+			if err != nil {
+				// Schedule became invalid; stop retrying it every tick
+				config.DB.Model(u).Update("next_run_at", nil)
+				continue
+			}
+
+			// Only the schedule columns are written, so results saved by a
+			// worker since the query are kept, and a URL queued meanwhile is
+			// left alone
+			res := config.DB.Model(&models.URL{}).
+				Where("id = ? AND status NOT IN ?", u.ID, []string{"queued", "running"}).
+				Updates(map[string]interface{}{"status": "queued", "next_run_at": next})
+			if res.Error != nil {
+				log.Printf("Failed to requeue %s: %v", u.ID, res.Error)
+				continue
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+			u.NextRunAt = &next
+			u.Status = "queued"
+
+			websockethub.BroadcastStatusUpdate(map[string]interface{}{
+				"id":        u.ID,
+				"status":    u.Status,
+				"nextRunAt": u.NextRunAt,
+			})
+		}
+	}
+}
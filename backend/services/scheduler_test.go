@@ -0,0 +1,85 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/models"
+)
+
+func TestNextRun(t *testing.T) {
+	from := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		interval int
+		cron     string
+		want     time.Time
+		wantErr  bool
+	}{
+		{"interval", 3600, "", from.Add(time.Hour), false},
+		{"daily cron later today", 0, "45 10 * * *", time.Date(2024, 5, 1, 10, 45, 0, 0, time.UTC), false},
+		{"daily cron already passed", 0, "0 6 * * *", time.Date(2024, 5, 2, 6, 0, 0, 0, time.UTC), false},
+		{"cron at from is strictly after", 0, "30 10 * * *", time.Date(2024, 5, 2, 10, 30, 0, 0, time.UTC), false},
+		{"descriptor", 0, "@monthly", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), false},
+		{"weekday cron", 0, "0 9 * * MON", time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC), false},
+		{"cron wins over interval", 60, "0 6 * * *", time.Date(2024, 5, 2, 6, 0, 0, 0, time.UTC), false},
+		{"invalid cron", 0, "not a cron", time.Time{}, true},
+		{"no schedule", 0, "", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &models.URL{ScheduleInterval: tt.interval, ScheduleCron: tt.cron}
+			got, err := NextRun(u, from)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NextRun error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("NextRun = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplySchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     ScheduleRequest
+		wantErr string
+	}{
+		{"interval", ScheduleRequest{Interval: 300}, ""},
+		{"minimum interval", ScheduleRequest{Interval: 60}, ""},
+		{"cron", ScheduleRequest{Cron: "*/15 * * * *"}, ""},
+		{"neither", ScheduleRequest{}, "at least 60 seconds"},
+		{"interval too short", ScheduleRequest{Interval: 59}, "at least 60 seconds"},
+		{"negative interval", ScheduleRequest{Interval: -5}, "must be positive"},
+		{"negative interval with cron", ScheduleRequest{Interval: -5, Cron: "@daily"}, "must be positive"},
+		{"interval and cron", ScheduleRequest{Interval: 300, Cron: "@daily"}, "not both"},
+		{"invalid cron", ScheduleRequest{Cron: "61 * * * *"}, "invalid cron expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &models.URL{SchedulePaused: true}
+			before := time.Now()
+			err := ApplySchedule(u, tt.req)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplySchedule(%+v) error = %v, want %q", tt.req, err, tt.wantErr)
+				}
+				if !u.SchedulePaused || u.NextRunAt != nil {
+					t.Error("a rejected schedule modified the URL")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplySchedule(%+v): %v", tt.req, err)
+			}
+			if u.ScheduleInterval != tt.req.Interval || u.ScheduleCron != tt.req.Cron || u.SchedulePaused {
+				t.Errorf("schedule stored as %d/%q paused %v", u.ScheduleInterval, u.ScheduleCron, u.SchedulePaused)
+			}
+			if u.NextRunAt == nil || !u.NextRunAt.After(before) {
+				t.Errorf("NextRunAt = %v, want a time after %v", u.NextRunAt, before)
+			}
+		})
+	}
+}
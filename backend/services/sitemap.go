@@ -0,0 +1,371 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/DMequanint/url-analyzer-pro/pkg/websockethub"
+	"gorm.io/gorm"
+)
+
+// Limits applied while walking sitemap indexes.
+const (
+	maxSitemapFiles = 50       // Sitemap files fetched per import
+	maxSitemapDepth = 3        // Nesting depth of sitemap indexes
+	maxSitemapBytes = 50 << 20 // Uncompressed size limit from the sitemaps.org protocol
+)
+
+// sitemapImportTimeout bounds a whole import: fetching the sitemaps and probing their pages.
+const sitemapImportTimeout = 10 * time.Minute
+
+// sitemapDocument matches both <urlset> and <sitemapindex> root elements.
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapURL `xml:"url"`
+	Sitemaps []sitemapRef `xml:"sitemap"`
+}
+
+// sitemapURL is a <url> element of a <urlset>.
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	Priority   string `xml:"priority"`
+}
+
+// sitemapRef is a <sitemap> element of a <sitemapindex>.
+type sitemapRef struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+/*
+ImportSitemap fetches every sitemap reachable from s.URL, records the
+listed pages as SitemapEntry rows, probes each of them for 404s and
+redirects (as far as the robots.txt policy allows), and queues them for
+analysis, reusing the URL records of pages imported before. The whole
+import is bounded by sitemapImportTimeout.
+
+s.URL may point at a sitemap, a sitemap index (optionally gzip-compressed)
+or a site root. For a site root, the sitemaps declared in robots.txt are
+used, falling back to /sitemap.xml. The import record is updated in place
+and broadcast over WebSocket when it finishes.
+*/
+func ImportSitemap(s *models.Sitemap) {
+	err := importSitemap(s)
+
+	now := time.Now()
+	s.FinishedAt = &now
+	if err != nil {
+		s.Status = "error"
+		s.ErrorReason = err.Error()
+	} else {
+		s.Status = "done"
+	}
+	config.DB.Save(s)
+
+	websockethub.BroadcastStatusUpdate(map[string]interface{}{
+		"sitemapId":  s.ID,
+		"status":     s.Status,
+		"urlsQueued": s.URLsQueued,
+		"notFound":   s.NotFound,
+		"redirected": s.Redirected,
+	})
+}
+
+func importSitemap(s *models.Sitemap) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sitemapImportTimeout)
+	defer cancel()
+
+	client := newHTTPClient(nil, s.URL)
+	defer client.CloseIdleConnections()
+
+	entries, files, err := collectSitemapEntries(ctx, client, s.URL)
+	if err != nil {
+		return err
+	}
+	s.Files = files
+	s.URLsFound = len(entries)
+
+	if limit := config.Analyzer.SitemapMaxURLs; len(entries) > limit {
+		entries = entries[:limit]
+	}
+	probeSitemapEntries(ctx, client, entries)
+
+	// All pages and entries are stored together, so a failed import leaves no orphans
+	queued, notFound, redirected := 0, 0, 0
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range entries {
+			e := &entries[i]
+			e.SitemapID = s.ID
+
+			page, requeued, err := queueSitemapPage(tx, e.Loc)
+			if err != nil {
+				return err
+			}
+			e.URLID = page.ID
+			if requeued {
+				queued++
+			}
+
+			switch {
+			case e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone:
+				notFound++
+			case e.StatusCode >= 300 && e.StatusCode < 400:
+				redirected++
+			}
+		}
+		if len(entries) > 0 {
+			return tx.CreateInBatches(entries, 100).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.URLsQueued = queued
+	s.NotFound = notFound
+	s.Redirected = redirected
+	return nil
+}
+
+/*
+queueSitemapPage queues loc for analysis, reusing the standalone URL
+record with the same normalized URL when there is one, so importing a
+sitemap again does not duplicate its pages. An existing record is queued
+again unless it is already queued or running; requeued reports whether
+the page was (re)queued.
+*/
+func queueSitemapPage(tx *gorm.DB, loc string) (page models.URL, requeued bool, err error) {
+	normalized, err := NormalizeURL(loc)
+	if err != nil {
+		normalized = loc
+	}
+
+	err = tx.Where("normalized_url = ? AND crawl_id IS NULL AND parent_url_id IS NULL AND has_request_profile = ?", normalized, false).
+		Order("created_at asc").First(&page).Error
+	switch {
+	case err == nil:
+		if page.Status == "queued" || page.Status == "running" {
+			return page, false, nil
+		}
+		if err := tx.Model(&page).Update("status", "queued").Error; err != nil {
+			return page, false, err
+		}
+		return page, true, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return page, false, err
+	}
+
+	page = models.URL{
+		URL:           loc,
+		NormalizedURL: normalized,
+		Status:        "queued",
+		JobType:       JobTypePage,
+	}
+	if err := tx.Create(&page).Error; err != nil {
+		return page, false, err
+	}
+	return page, true, nil
+}
+
+/*
+collectSitemapEntries walks the sitemap tree rooted at rawURL and returns
+the de-duplicated page entries together with the number of files fetched.
+*/
+func collectSitemapEntries(ctx context.Context, client *http.Client, rawURL string) ([]models.SitemapEntry, int, error) {
+	type pending struct {
+		url   string
+		depth int
+	}
+
+	doc, err := fetchSitemap(ctx, client, rawURL)
+	queue := []pending{{rawURL, 0}}
+	if err != nil {
+		// Not a sitemap: treat the URL as a site and discover its sitemaps
+		queue = nil
+		for _, sm := range discoverSitemaps(ctx, client, rawURL) {
+			queue = append(queue, pending{sm, 0})
+		}
+		if len(queue) == 0 {
+			return nil, 0, fmt.Errorf("no sitemap found at %s: %v", rawURL, err)
+		}
+		doc = nil
+	}
+
+	var entries []models.SitemapEntry
+	seenFiles := map[string]bool{}
+	seenPages := map[string]bool{}
+	files := 0
+
+	for len(queue) > 0 && files < maxSitemapFiles {
+		next := queue[0]
+		queue = queue[1:]
+		if seenFiles[next.url] {
+			continue
+		}
+		seenFiles[next.url] = true
+
+		current := doc
+		doc = nil
+		if current == nil {
+			if current, err = fetchSitemap(ctx, client, next.url); err != nil {
+				continue
+			}
+		}
+		files++
+
+		for _, ref := range current.Sitemaps {
+			if loc := strings.TrimSpace(ref.Loc); loc != "" && next.depth < maxSitemapDepth {
+				queue = append(queue, pending{resolveURL(next.url, loc), next.depth + 1})
+			}
+		}
+		for _, u := range current.URLs {
+			loc := strings.TrimSpace(u.Loc)
+			if loc == "" || seenPages[loc] {
+				continue
+			}
+			seenPages[loc] = true
+
+			entry := models.SitemapEntry{
+				Source:     next.url,
+				Loc:        loc,
+				LastMod:    strings.TrimSpace(u.LastMod),
+				ChangeFreq: strings.TrimSpace(u.ChangeFreq),
+			}
+			if p, err := strconv.ParseFloat(strings.TrimSpace(u.Priority), 64); err == nil {
+				entry.Priority = &p
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	if files == 0 {
+		return nil, 0, errors.New("no sitemap could be fetched")
+	}
+	return entries, files, nil
+}
+
+/*
+fetchSitemap downloads and decodes a single sitemap or sitemap index,
+transparently decompressing gzip payloads (detected by magic bytes, since
+servers often send .xml.gz files without a Content-Encoding header).
+*/
+func fetchSitemap(ctx context.Context, client *http.Client, rawURL string) (*sitemapDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, errors.New("unreachable: " + resp.Status)
+	}
+
+	body := bufio.NewReader(resp.Body)
+	var r io.Reader = body
+	if magic, err := body.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(io.LimitReader(r, maxSitemapBytes)).Decode(&doc); err != nil {
+		return nil, err
+	}
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+		return &doc, nil
+	}
+	return nil, fmt.Errorf("unexpected root element <%s>", doc.XMLName.Local)
+}
+
+// discoverSitemaps returns the sitemaps declared in a site's robots.txt, or its /sitemap.xml.
+func discoverSitemaps(ctx context.Context, client *http.Client, siteURL string) []string {
+	if rules, err := robotsFor(ctx, client, siteURL); err == nil && len(rules.sitemaps) > 0 {
+		return rules.sitemaps
+	}
+	return []string{resolveURL(siteURL, "/sitemap.xml")}
+}
+
+/*
+probeSitemapEntries requests every listed page without following redirects
+and records the status code (and redirect target) on each entry. Pages
+disallowed by the robots.txt policy, or held back by the per-host limits,
+are left unprobed.
+*/
+func probeSitemapEntries(ctx context.Context, client *http.Client, entries []models.SitemapEntry) {
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	workers := config.Analyzer.LinkCheckWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	semaphore := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+	for i := range entries {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(e *models.SitemapEntry) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			_, proceed, err := robotsCheck(ctx, client, e.Loc)
+			if errors.Is(err, errHostThrottled) || (err == nil && !proceed) {
+				return
+			}
+			if !proceed {
+				e.Error = err.Error()
+				return
+			}
+
+			resp, err := probeSitemapEntry(ctx, &noRedirect, http.MethodHead, e.Loc)
+			if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+				resp.Body.Close()
+				resp, err = probeSitemapEntry(ctx, &noRedirect, http.MethodGet, e.Loc)
+			}
+			if err != nil {
+				e.Error = err.Error()
+				return
+			}
+			resp.Body.Close()
+			e.StatusCode = resp.StatusCode
+			if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+				e.Location = resolveURL(e.Loc, resp.Header.Get("Location"))
+			}
+		}(&entries[i])
+	}
+	wg.Wait()
+}
+
+// probeSitemapEntry sends a single probe request for a listed page.
+func probeSitemapEntry(ctx context.Context, client *http.Client, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testURLSet = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc> https://example.com/ </loc>
+    <lastmod>2024-05-01</lastmod>
+    <changefreq>daily</changefreq>
+    <priority>1.0</priority>
+  </url>
+  <url><loc>https://example.com/about</loc><priority>high</priority></url>
+  <url><loc></loc></url>
+</urlset>`
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newSitemapServer serves the given bodies by path and 404 for anything else.
+func newSitemapServer(t *testing.T, files map[string][]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchSitemap(t *testing.T) {
+	srv := newSitemapServer(t, map[string][]byte{
+		"/sitemap.xml":    []byte(testURLSet),
+		"/sitemap.xml.gz": gzipBytes(t, testURLSet),
+		"/index.xml":      []byte(`<sitemapindex><sitemap><loc>https://example.com/a.xml</loc></sitemap></sitemapindex>`),
+		"/feed.xml":       []byte(`<rss version="2.0"><channel></channel></rss>`),
+		"/broken.xml":     []byte(`<urlset><url><loc>https://example.com/</loc>`),
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		urls     int
+		sitemaps int
+		wantErr  bool
+	}{
+		{"urlset", "/sitemap.xml", 3, 0, false},
+		{"gzip without Content-Encoding", "/sitemap.xml.gz", 3, 0, false},
+		{"sitemap index", "/index.xml", 0, 1, false},
+		{"unexpected root element", "/feed.xml", 0, 0, true},
+		{"malformed XML", "/broken.xml", 0, 0, true},
+		{"missing file", "/missing.xml", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := fetchSitemap(context.Background(), srv.Client(), srv.URL+tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("fetchSitemap(%s) succeeded, want an error", tt.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("fetchSitemap(%s): %v", tt.path, err)
+			}
+			if len(doc.URLs) != tt.urls || len(doc.Sitemaps) != tt.sitemaps {
+				t.Errorf("fetchSitemap(%s) = %d urls, %d sitemaps; want %d, %d",
+					tt.path, len(doc.URLs), len(doc.Sitemaps), tt.urls, tt.sitemaps)
+			}
+		})
+	}
+}
+
+func TestCollectSitemapEntries(t *testing.T) {
+	files := map[string][]byte{
+		"/pages.xml": []byte(testURLSet),
+		"/more.xml.gz": gzipBytes(t, `<urlset>
+			<url><loc>https://example.com/about</loc></url>
+			<url><loc>https://example.com/contact</loc></url>
+		</urlset>`),
+		// The index refers to itself and to a missing file, which are skipped
+		"/index.xml": []byte(`<sitemapindex>
+			<sitemap><loc>/pages.xml</loc></sitemap>
+			<sitemap><loc>more.xml.gz</loc></sitemap>
+			<sitemap><loc>/index.xml</loc></sitemap>
+			<sitemap><loc>/missing.xml</loc></sitemap>
+		</sitemapindex>`),
+	}
+	srv := newSitemapServer(t, files)
+
+	entries, fetched, err := collectSitemapEntries(context.Background(), srv.Client(), srv.URL+"/index.xml")
+	if err != nil {
+		t.Fatalf("collectSitemapEntries: %v", err)
+	}
+	if fetched != 3 {
+		t.Errorf("fetched %d files, want 3", fetched)
+	}
+
+	want := []struct {
+		loc      string
+		source   string
+		priority float64 // -1 for none
+	}{
+		{"https://example.com/", "/pages.xml", 1.0},
+		{"https://example.com/about", "/pages.xml", -1},
+		{"https://example.com/contact", "/more.xml.gz", -1},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Loc != w.loc || e.Source != srv.URL+w.source {
+			t.Errorf("entry %d = %s from %s, want %s from %s", i, e.Loc, e.Source, w.loc, srv.URL+w.source)
+		}
+		switch {
+		case w.priority < 0 && e.Priority != nil:
+			t.Errorf("entry %d priority = %v, want none", i, *e.Priority)
+		case w.priority >= 0 && (e.Priority == nil || *e.Priority != w.priority):
+			t.Errorf("entry %d priority = %v, want %v", i, e.Priority, w.priority)
+		}
+	}
+	if first := entries[0]; first.LastMod != "2024-05-01" || first.ChangeFreq != "daily" {
+		t.Errorf("first entry lastmod/changefreq = %q/%q, want 2024-05-01/daily", first.LastMod, first.ChangeFreq)
+	}
+}
+
+func TestCollectSitemapEntriesFallsBackToSiteRoot(t *testing.T) {
+	srv := newSitemapServer(t, map[string][]byte{
+		"/sitemap.xml": []byte(testURLSet),
+	})
+
+	entries, fetched, err := collectSitemapEntries(context.Background(), srv.Client(), srv.URL+"/")
+	if err != nil {
+		t.Fatalf("collectSitemapEntries: %v", err)
+	}
+	if fetched != 1 || len(entries) != 2 {
+		t.Errorf("got %d entries from %d files, want 2 from /sitemap.xml", len(entries), fetched)
+	}
+}
+
+func TestCollectSitemapEntriesWithoutSitemap(t *testing.T) {
+	srv := newSitemapServer(t, nil)
+	if _, _, err := collectSitemapEntries(context.Background(), srv.Client(), srv.URL+"/"); err == nil {
+		t.Error("collectSitemapEntries succeeded for a site without sitemaps, want an error")
+	}
+}
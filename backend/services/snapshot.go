@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/config"
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"github.com/DMequanint/url-analyzer-pro/pkg/blobstore"
+)
+
+// ErrSnapshotsDisabled is returned when no snapshot store is configured.
+var ErrSnapshotsDisabled = errors.New("response snapshots are disabled")
+
+// snapshots is the blob store receiving response snapshots; nil when disabled.
+var snapshots blobstore.Store
+
+// snapshotGCMu serializes blob garbage collection with the writes adding new references.
+var snapshotGCMu sync.Mutex
+
+/*
+InitSnapshotStore opens the blob store selected by SNAPSHOT_STORE. With
+the "none" store, snapshots are not captured at all.
+*/
+func InitSnapshotStore() error {
+	cfg := config.Analyzer
+	switch cfg.SnapshotStore {
+	case config.SnapshotStoreFilesystem:
+		dir, err := filepath.Abs(cfg.SnapshotDir)
+		if err != nil {
+			return err
+		}
+		store, err := blobstore.NewFilesystem(dir)
+		if err != nil {
+			return err
+		}
+		snapshots = store
+	case config.SnapshotStoreS3:
+		store, err := blobstore.NewS3(blobstore.S3Options{
+			Endpoint:  cfg.SnapshotS3Endpoint,
+			Bucket:    cfg.SnapshotS3Bucket,
+			Prefix:    cfg.SnapshotS3Prefix,
+			Region:    cfg.SnapshotS3Region,
+			AccessKey: cfg.SnapshotS3Access,
+			SecretKey: cfg.SnapshotS3Secret,
+			UseSSL:    !cfg.SnapshotS3Insecure,
+		})
+		if err != nil {
+			return err
+		}
+		snapshots = store
+	default:
+		snapshots = nil
+	}
+	return nil
+}
+
+/*
+newCapture starts capturing the page response for a snapshot, recording
+the final URL, status line and headers. It returns nil when snapshots are
+disabled.
+*/
+func newCapture(resp *http.Response) *models.ResponseCapture {
+	if snapshots == nil {
+		return nil
+	}
+	var headers bytes.Buffer
+	fmt.Fprintf(&headers, "%s %s\r\n", resp.Proto, resp.Status)
+	resp.Header.Write(&headers)
+
+	return &models.ResponseCapture{
+		FinalURL:    resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Headers:     headers.Bytes(),
+	}
+}
+
+/*
+captureWriter appends written bytes to a capture's body up to
+SNAPSHOT_MAX_BYTES and flags the capture as truncated beyond that. It
+never fails, so it can sit in a TeeReader without disturbing parsing.
+*/
+type captureWriter struct {
+	c *models.ResponseCapture
+}
+
+func (w captureWriter) Write(p []byte) (int, error) {
+	if w.c == nil {
+		return len(p), nil
+	}
+	room := config.Analyzer.SnapshotMaxBytes - int64(len(w.c.Body))
+	if int64(len(p)) > room {
+		w.c.Truncated = true
+		if room > 0 {
+			w.c.Body = append(w.c.Body, p[:room]...)
+		}
+		return len(p), nil
+	}
+	w.c.Body = append(w.c.Body, p...)
+	return len(p), nil
+}
+
+/*
+StoreSnapshot writes the response captured during run to the blob store
+and records it as the run's Snapshot, then applies the retention limits
+to the URL's snapshots. Runs without a capture are skipped.
+*/
+func StoreSnapshot(run *models.AnalysisRun) error {
+	c := run.Capture
+	if snapshots == nil || c == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	redacted, omitted := redactCapture(c)
+	if err := saveSnapshot(ctx, run, c, redacted, omitted); err != nil {
+		return err
+	}
+	pruneSnapshots(run.URLID)
+	return nil
+}
+
+/*
+saveSnapshot writes the blobs of c and the Snapshot row referring to them.
+Put skips blobs that already exist, so the write and the row are done
+under snapshotGCMu: otherwise garbage collection could delete an existing
+blob between the skipped write and the new reference.
+*/
+func saveSnapshot(ctx context.Context, run *models.AnalysisRun, c *models.ResponseCapture, redacted, omitted bool) error {
+	snapshotGCMu.Lock()
+	defer snapshotGCMu.Unlock()
+
+	bodyKey := blobstore.Key(c.Body)
+	if err := snapshots.Put(ctx, bodyKey, c.Body, "application/octet-stream"); err != nil {
+		return err
+	}
+	headersKey := blobstore.Key(c.Headers)
+	if err := snapshots.Put(ctx, headersKey, c.Headers, "text/plain"); err != nil {
+		return err
+	}
+
+	snap := models.Snapshot{
+		RunID:         run.ID,
+		URLID:         run.URLID,
+		FinalURL:      c.FinalURL,
+		StatusCode:    c.StatusCode,
+		ContentType:   c.ContentType,
+		BodyHash:      bodyKey,
+		BodySize:      int64(len(c.Body)),
+		BodyTruncated: c.Truncated,
+		BodyRedacted:  redacted,
+		BodyOmitted:   omitted,
+		HeadersHash:   headersKey,
+	}
+	var existing models.Snapshot
+	if err := config.DB.Select("id").Where("run_id = ?", run.ID).First(&existing).Error; err == nil {
+		snap.ID = existing.ID
+	}
+	return config.DB.Save(&snap).Error
+}
+
+/*
+redactCapture masks PII in a capture before it is stored, when
+SNAPSHOT_REDACT_PII is set; by default the raw response is kept. Headers
+and textual bodies get their matches replaced, while PDF bodies, whose
+text cannot be rewritten in place, are dropped. It reports whether the
+capture was redacted and whether its body was omitted.
+*/
+func redactCapture(c *models.ResponseCapture) (redacted, omitted bool) {
+	if !config.Analyzer.SnapshotRedactPII {
+		return false, false
+	}
+	c.Headers = []byte(redactPIIText(string(c.Headers)))
+	head := c.Body
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	if detectDocumentType(c.ContentType, head) == DocumentPDF {
+		c.Body = nil
+		return true, true
+	}
+	c.Body = []byte(redactPIIText(string(c.Body)))
+	return true, false
+}
+
+/*
+pruneSnapshots enforces SNAPSHOT_KEEP_RUNS for the URL and
+SNAPSHOT_MAX_AGE_DAYS for all URLs, deleting blobs no snapshot refers to
+anymore.
+*/
+func pruneSnapshots(urlID string) {
+	var expired []models.Snapshot
+	if keep := config.Analyzer.SnapshotKeepRuns; keep > 0 {
+		var all []models.Snapshot
+		if err := config.DB.Where("url_id = ?", urlID).Order("created_at desc").Find(&all).Error; err != nil {
+			log.Printf("Failed to list snapshots of %s: %v", urlID, err)
+		} else if len(all) > keep {
+			expired = append(expired, all[keep:]...)
+		}
+	}
+	if maxAge := config.Analyzer.SnapshotMaxAge; maxAge > 0 {
+		var old []models.Snapshot
+		if err := config.DB.Where("created_at < ?", time.Now().Add(-maxAge)).Find(&old).Error; err != nil {
+			log.Printf("Failed to list expired snapshots: %v", err)
+		} else {
+			expired = append(expired, old...)
+		}
+	}
+	deleteSnapshots(expired)
+}
+
+// DeleteSnapshots removes every snapshot of a URL along with its unreferenced blobs.
+func DeleteSnapshots(urlID string) error {
+	var snaps []models.Snapshot
+	if err := config.DB.Where("url_id = ?", urlID).Find(&snaps).Error; err != nil {
+		return err
+	}
+	deleteSnapshots(snaps)
+	return nil
+}
+
+/*
+deleteSnapshots deletes the given snapshot rows, then any blob left
+without references. Blobs are only deleted when their reference count
+could be read, and under snapshotGCMu (see saveSnapshot).
+*/
+func deleteSnapshots(snaps []models.Snapshot) {
+	if len(snaps) == 0 {
+		return
+	}
+	snapshotGCMu.Lock()
+	defer snapshotGCMu.Unlock()
+
+	ids := make([]uint, 0, len(snaps))
+	keys := map[string]bool{}
+	for _, s := range snaps {
+		ids = append(ids, s.ID)
+		keys[s.BodyHash] = true
+		keys[s.HeadersHash] = true
+	}
+	if err := config.DB.Delete(&models.Snapshot{}, ids).Error; err != nil {
+		log.Printf("Failed to delete snapshots: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for key := range keys {
+		var refs int64
+		if err := config.DB.Model(&models.Snapshot{}).Where("body_hash = ? OR headers_hash = ?", key, key).Count(&refs).Error; err != nil {
+			log.Printf("Failed to count references to snapshot blob %s: %v", key, err)
+			continue
+		}
+		if refs > 0 || snapshots == nil {
+			continue
+		}
+		if err := snapshots.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete snapshot blob %s: %v", key, err)
+		}
+	}
+}
+
+/*
+OpenSnapshotBlob opens a stored snapshot body or header blob for reading.
+Returns ErrSnapshotsDisabled when no store is configured and
+blobstore.ErrNotFound when the blob is missing.
+*/
+func OpenSnapshotBlob(ctx context.Context, key string) (io.ReadCloser, error) {
+	if snapshots == nil {
+		return nil, ErrSnapshotsDisabled
+	}
+	return snapshots.Open(ctx, key)
+}
+
+// captureErrorBody keeps up to SNAPSHOT_MAX_BYTES of an error response body in c.
+func captureErrorBody(c *models.ResponseCapture, body io.Reader) {
+	if c == nil {
+		return
+	}
+	io.Copy(captureWriter{c}, io.LimitReader(body, config.Analyzer.SnapshotMaxBytes+1))
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"golang.org/x/net/html"
+)
+
+// Structured data formats recorded in StructuredItem.Format.
+const (
+	FormatJSONLD    = "json-ld"
+	FormatMicrodata = "microdata"
+	FormatRDFa      = "rdfa"
+)
+
+/*
+requiredProperties lists, per schema.org type, the properties an entity
+must carry to be eligible for search features. Alternatives separated by
+"|" are satisfied by any one of them.
+*/
+var requiredProperties = map[string][]string{
+	"Product":        {"name", "offers|review|aggregateRating"},
+	"Article":        {"headline", "author", "datePublished"},
+	"NewsArticle":    {"headline", "author", "datePublished"},
+	"BlogPosting":    {"headline", "author", "datePublished"},
+	"Organization":   {"name", "url"},
+	"BreadcrumbList": {"itemListElement"},
+	"FAQPage":        {"mainEntity"},
+}
+
+/*
+extractStructuredData collects the schema.org entities of a page from
+JSON-LD script blocks, Microdata (itemscope/itemprop) and RDFa
+(typeof/property), and validates each against requiredProperties.
+*/
+func extractStructuredData(doc *html.Node, pageURL string) []models.StructuredItem {
+	items := []models.StructuredItem{}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case strings.EqualFold(n.Data, "script") &&
+				strings.EqualFold(strings.TrimSpace(attrValue(n, "type")), "application/ld+json"):
+				items = append(items, parseJSONLD(nodeRawText(n))...)
+				return
+
+			case hasAttr(n, "itemscope") && !hasAttr(n, "itemprop"):
+				props := microdataProperties(n, pageURL)
+				items = append(items, models.StructuredItem{
+					Format:     FormatMicrodata,
+					Type:       schemaType(attrValue(n, "itemtype")),
+					Properties: props,
+				})
+
+			case hasAttr(n, "typeof") && !hasAttr(n, "property"):
+				props := rdfaProperties(n, pageURL)
+				items = append(items, models.StructuredItem{
+					Format:     FormatRDFa,
+					Type:       schemaType(attrValue(n, "typeof")),
+					Properties: props,
+				})
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	for i := range items {
+		if items[i].Error == "" {
+			items[i].Missing = missingProperties(items[i].Type, items[i].Properties)
+		}
+	}
+	return items
+}
+
+// nodeRawText concatenates the raw text children of n (script contents are a single text node).
+func nodeRawText(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+		}
+	}
+	return sb.String()
+}
+
+/*
+parseJSONLD decodes a JSON-LD block into top-level entities, unwrapping
+arrays and @graph containers. A block that is not valid JSON yields a
+single item carrying the parse error.
+*/
+func parseJSONLD(raw string) []models.StructuredItem {
+	var data interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &data); err != nil {
+		return []models.StructuredItem{{Format: FormatJSONLD, Error: err.Error()}}
+	}
+
+	var items []models.StructuredItem
+	var collect func(interface{})
+	collect = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, e := range v {
+				collect(e)
+			}
+		case map[string]interface{}:
+			if graph, ok := v["@graph"]; ok {
+				collect(graph)
+				return
+			}
+			items = append(items, models.StructuredItem{
+				Format:     FormatJSONLD,
+				Type:       schemaType(jsonLDType(v["@type"])),
+				Properties: v,
+			})
+		}
+	}
+	collect(data)
+	return items
+}
+
+// jsonLDType returns the first @type of a JSON-LD node, which may be a string or an array.
+func jsonLDType(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []interface{}:
+		if len(v) > 0 {
+			if s, ok := v[0].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+/*
+schemaType strips the vocabulary from a type reference, so that
+"https://schema.org/Product", "schema:Product" and "Product" all yield
+"Product". Only the first of several space-separated types is kept.
+*/
+func schemaType(raw string) string {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return ""
+	}
+	t := strings.TrimRight(fields[0], "/")
+	if i := strings.LastIndexAny(t, "/#:"); i >= 0 {
+		t = t[i+1:]
+	}
+	return t
+}
+
+/*
+microdataProperties collects the itemprop values below an itemscope
+element. Nested itemscope elements become nested objects and their own
+properties are not attributed to the outer item.
+*/
+func microdataProperties(scope *html.Node, pageURL string) map[string]interface{} {
+	props := map[string]interface{}{}
+	if t := attrValue(scope, "itemtype"); t != "" {
+		props["@type"] = schemaType(t)
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			names := strings.Fields(attrValue(c, "itemprop"))
+			nested := hasAttr(c, "itemscope")
+			if len(names) > 0 {
+				var value interface{}
+				if nested {
+					value = microdataProperties(c, pageURL)
+				} else {
+					value = elementValue(c, pageURL, "")
+				}
+				for _, name := range names {
+					addProperty(props, name, value)
+				}
+			}
+			if !nested {
+				walk(c)
+			}
+		}
+	}
+	walk(scope)
+	return props
+}
+
+/*
+rdfaProperties collects the RDFa Lite property values below a typeof
+element; nested typeof elements become nested objects.
+*/
+func rdfaProperties(scope *html.Node, pageURL string) map[string]interface{} {
+	props := map[string]interface{}{}
+	if t := attrValue(scope, "typeof"); t != "" {
+		props["@type"] = schemaType(t)
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			names := strings.Fields(attrValue(c, "property"))
+			nested := hasAttr(c, "typeof")
+			if len(names) > 0 {
+				var value interface{}
+				if nested {
+					value = rdfaProperties(c, pageURL)
+				} else {
+					value = elementValue(c, pageURL, attrValue(c, "resource"))
+				}
+				for _, name := range names {
+					addProperty(props, schemaType(name), value)
+				}
+			}
+			if !nested {
+				walk(c)
+			}
+		}
+	}
+	walk(scope)
+	return props
+}
+
+/*
+elementValue returns the value of a Microdata or RDFa property element:
+its content attribute, its URL attribute for links and media, machine
+readable values for time/data/meter, or its text.
+*/
+func elementValue(n *html.Node, pageURL, resource string) string {
+	if v, ok := attrLookup(n, "content"); ok {
+		return strings.TrimSpace(v)
+	}
+	if resource != "" {
+		return resolveURL(pageURL, resource)
+	}
+	switch strings.ToLower(n.Data) {
+	case "a", "link", "area":
+		return resolveURL(pageURL, strings.TrimSpace(attrValue(n, "href")))
+	case "img", "audio", "video", "source", "track", "iframe", "embed":
+		return resolveURL(pageURL, strings.TrimSpace(attrValue(n, "src")))
+	case "object":
+		return resolveURL(pageURL, strings.TrimSpace(attrValue(n, "data")))
+	case "time":
+		if v, ok := attrLookup(n, "datetime"); ok {
+			return strings.TrimSpace(v)
+		}
+	case "data", "meter":
+		return strings.TrimSpace(attrValue(n, "value"))
+	}
+	return nodeText(n)
+}
+
+// attrLookup returns the value of attribute key on n and whether it is present.
+func attrLookup(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// addProperty stores value under name, turning repeated properties into a list.
+func addProperty(props map[string]interface{}, name string, value interface{}) {
+	existing, ok := props[name]
+	if !ok {
+		props[name] = value
+		return
+	}
+	if list, ok := existing.([]interface{}); ok {
+		props[name] = append(list, value)
+		return
+	}
+	props[name] = []interface{}{existing, value}
+}
+
+/*
+missingProperties returns the required properties of the given type that
+props lacks, including the per-entry requirements of BreadcrumbList
+items (position, name) and FAQPage questions (name, acceptedAnswer).
+*/
+func missingProperties(itemType string, props map[string]interface{}) []string {
+	var missing []string
+	for _, req := range requiredProperties[itemType] {
+		if !hasAnyProperty(props, strings.Split(req, "|")) {
+			missing = append(missing, req)
+		}
+	}
+
+	switch itemType {
+	case "BreadcrumbList":
+		for i, e := range propertyList(props["itemListElement"]) {
+			entry, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if !hasProperty(entry, "position") {
+				missing = append(missing, listPath("itemListElement", i, "position"))
+			}
+			if !hasProperty(entry, "name") && !hasNestedName(entry["item"]) {
+				missing = append(missing, listPath("itemListElement", i, "name"))
+			}
+		}
+	case "FAQPage":
+		for i, q := range propertyList(props["mainEntity"]) {
+			question, ok := q.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if !hasProperty(question, "name") {
+				missing = append(missing, listPath("mainEntity", i, "name"))
+			}
+			// acceptedAnswer may be a single Answer or a list of them; each needs its text
+			answers := propertyList(question["acceptedAnswer"])
+			complete := len(answers) > 0
+			for _, a := range answers {
+				answer, ok := a.(map[string]interface{})
+				complete = complete && ok && hasProperty(answer, "text")
+			}
+			if !complete {
+				missing = append(missing, listPath("mainEntity", i, "acceptedAnswer.text"))
+			}
+		}
+	}
+	return missing
+}
+
+// hasProperty reports whether props has a non-empty value for name.
+func hasProperty(props map[string]interface{}, name string) bool {
+	switch v := props[name].(type) {
+	case nil:
+		return false
+	case string:
+		return strings.TrimSpace(v) != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// hasAnyProperty reports whether props has a non-empty value for any of names.
+func hasAnyProperty(props map[string]interface{}, names []string) bool {
+	for _, name := range names {
+		if hasProperty(props, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNestedName reports whether a breadcrumb "item" value is an object with a name.
+func hasNestedName(v interface{}) bool {
+	item, ok := v.(map[string]interface{})
+	return ok && hasProperty(item, "name")
+}
+
+// propertyList returns a property value as a list, wrapping single values.
+func propertyList(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	}
+	return []interface{}{v}
+}
+
+// listPath formats the location of a property inside a list entry, e.g. mainEntity[2].name.
+func listPath(list string, index int, prop string) string {
+	return list + "[" + strconv.Itoa(index) + "]." + prop
+}
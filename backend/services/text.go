@@ -0,0 +1,208 @@
+package services
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"golang.org/x/net/html"
+)
+
+// topKeywordCount is the number of keywords kept per page.
+const topKeywordCount = 10
+
+// invisibleTags are elements whose text is not part of the readable page body.
+var invisibleTags = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"nav": true, "svg": true, "iframe": true, "object": true, "select": true,
+}
+
+// blockTags end a run of text, so adjacent blocks do not merge into one sentence.
+var blockTags = map[string]bool{
+	"p": true, "div": true, "li": true, "ul": true, "ol": true, "dt": true, "dd": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"section": true, "article": true, "aside": true, "header": true, "footer": true,
+	"main": true, "blockquote": true, "pre": true, "table": true, "tr": true,
+	"td": true, "th": true, "br": true, "hr": true, "figcaption": true, "form": true,
+}
+
+// sentenceBreak splits text on terminal punctuation followed by whitespace, or on block boundaries.
+var sentenceBreak = regexp.MustCompile(`[.!?]+(\s+|$)|\n+`)
+
+// stopwords are common English words excluded from the keyword list.
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`a about above after again against all also am an and any are
+		as at be because been before being below between both but by can could did do does doing
+		down during each few for from further had has have having he her here hers herself him
+		himself his how i if in into is it its itself just me more most my myself no nor not now
+		of off on once only or other our ours ourselves out over own same she should so some such
+		than that the their theirs them themselves then there these they this those through to
+		too under until up very was we were what when where which while who whom why will with
+		would you your yours yourself yourselves`) {
+		stopwords[w] = true
+	}
+}
+
+/*
+visibleText returns the text a reader sees in the page body: text nodes
+outside scripts, styles, navigation and hidden elements, with whitespace
+collapsed and block elements separated by newlines.
+*/
+func visibleText(doc *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			if text := strings.Join(strings.Fields(n.Data), " "); text != "" {
+				sb.WriteString(text)
+				sb.WriteByte(' ')
+			}
+			return
+		case html.ElementNode:
+			tag := strings.ToLower(n.Data)
+			if invisibleTags[tag] || hasAttr(n, "hidden") || strings.EqualFold(attrValue(n, "aria-hidden"), "true") {
+				return
+			}
+			if blockTags[tag] {
+				defer sb.WriteByte('\n')
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	lines := strings.Split(sb.String(), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// hasAttr reports whether n carries the attribute key, whatever its value.
+func hasAttr(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// textWords splits text into words made of letters, digits and inner apostrophes.
+func textWords(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’'
+	})
+	out := words[:0]
+	for _, w := range words {
+		if w = strings.Trim(w, "'’"); w != "" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+/*
+applyTextMetrics fills the content metrics of r from the page's visible
+text: word and sentence counts, Flesch reading ease and Flesch-Kincaid
+grade, text-to-HTML ratio and the most frequent keywords.
+*/
+func applyTextMetrics(r *models.AnalysisResult, text string, htmlBytes int64) {
+	words := textWords(text)
+	r.WordCount = len(words)
+
+	r.SentenceCount = 0
+	for _, s := range sentenceBreak.Split(text, -1) {
+		if len(textWords(s)) > 0 {
+			r.SentenceCount++
+		}
+	}
+
+	r.ReadingEase, r.ReadingGrade = 0, 0
+	if r.WordCount > 0 && r.SentenceCount > 0 {
+		syllables := 0
+		for _, w := range words {
+			syllables += countSyllables(w)
+		}
+		wordsPerSentence := float64(r.WordCount) / float64(r.SentenceCount)
+		syllablesPerWord := float64(syllables) / float64(r.WordCount)
+		r.ReadingEase = round1(206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord)
+		r.ReadingGrade = round1(0.39*wordsPerSentence + 11.8*syllablesPerWord - 15.59)
+	}
+
+	r.TextToHTMLRatio = 0
+	if htmlBytes > 0 {
+		r.TextToHTMLRatio = round1(float64(len(text)) / float64(htmlBytes) * 100)
+	}
+
+	r.TopKeywords = topKeywords(words, topKeywordCount)
+}
+
+// topKeywords returns the n most frequent words that are not stopwords, numbers or very short.
+func topKeywords(words []string, n int) []models.KeywordCount {
+	counts := map[string]int{}
+	for _, w := range words {
+		w = strings.ToLower(w)
+		if len([]rune(w)) < 3 || stopwords[w] || !strings.ContainsFunc(w, unicode.IsLetter) {
+			continue
+		}
+		counts[w]++
+	}
+
+	keywords := make([]models.KeywordCount, 0, len(counts))
+	for w, c := range counts {
+		keywords = append(keywords, models.KeywordCount{Word: w, Count: c})
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if keywords[i].Count != keywords[j].Count {
+			return keywords[i].Count > keywords[j].Count
+		}
+		return keywords[i].Word < keywords[j].Word
+	})
+	if len(keywords) > n {
+		keywords = keywords[:n]
+	}
+	return keywords
+}
+
+/*
+countSyllables estimates the syllables of an English word by counting
+vowel groups, ignoring a silent trailing "e". Every word has at least one.
+*/
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	isVowel := func(r rune) bool { return strings.ContainsRune("aeiouy", r) }
+
+	count := 0
+	prevVowel := false
+	for _, r := range word {
+		v := isVowel(r)
+		if v && !prevVowel {
+			count++
+		}
+		prevVowel = v
+	}
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+		count--
+	}
+	if count == 0 {
+		count = 1
+	}
+	return count
+}
+
+// round1 rounds f to one decimal place.
+func round1(f float64) float64 {
+	return math.Round(f*10) / 10
+}
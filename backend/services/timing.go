@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"math"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DMequanint/url-analyzer-pro/models"
+)
+
+/*
+fetchTimer records the phases of a request through httptrace. Hooks may
+fire concurrently (e.g. parallel dials for multiple addresses), so all
+state is guarded by mu.
+*/
+type fetchTimer struct {
+	mu           sync.Mutex
+	start        time.Time // First GetConn, i.e. after any politeness wait
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	connRequest  time.Time // GetConn of the latest hop
+	firstByte    time.Time // First response byte of the latest hop
+	end          time.Time // End of the body download, once stopped
+	dns          time.Duration
+	connect      time.Duration
+	tls          time.Duration
+	reused       bool
+}
+
+// withTrace returns ctx carrying a client trace that reports to t.
+func (t *fetchTimer) withTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			now := time.Now()
+			if t.start.IsZero() {
+				t.start = now
+			}
+			t.connRequest = now
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dns += time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			if !t.connectStart.IsZero() {
+				t.connect += time.Since(t.connectStart)
+				t.connectStart = time.Time{}
+			}
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.tls += time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.firstByte = time.Now()
+			t.mu.Unlock()
+		},
+	})
+}
+
+// stop marks the end of the body download; later calls are ignored.
+func (t *fetchTimer) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.end.IsZero() {
+		t.end = time.Now()
+	}
+}
+
+/*
+finish returns the timing breakdown. The download ends when stop was
+called, or now if the body was not read to the end.
+*/
+func (t *fetchTimer) finish() models.Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.end
+	if now.IsZero() {
+		now = time.Now()
+	}
+	timing := models.Timing{
+		DNSMs:     millis(t.dns),
+		ConnectMs: millis(t.connect),
+		TLSMs:     millis(t.tls),
+		Reused:    t.reused,
+	}
+	if !t.start.IsZero() {
+		timing.TotalMs = millis(now.Sub(t.start))
+	}
+	if !t.firstByte.IsZero() {
+		timing.TTFBMs = millis(t.firstByte.Sub(t.connRequest))
+		timing.DownloadMs = millis(now.Sub(t.firstByte))
+	}
+	return timing
+}
+
+// millis converts d to milliseconds rounded to 0.1 ms.
+func millis(d time.Duration) float64 {
+	return round1(float64(d) / float64(time.Millisecond))
+}
+
+// Percentiles summarizes a distribution of durations in milliseconds.
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P75 float64 `json:"p75"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// TimingPercentiles holds per-phase percentiles over a set of page fetches.
+type TimingPercentiles struct {
+	Count    int         `json:"count"` // Number of fetches included
+	DNS      Percentiles `json:"dns"`
+	Connect  Percentiles `json:"connect"`
+	TLS      Percentiles `json:"tls"`
+	TTFB     Percentiles `json:"ttfb"`
+	Download Percentiles `json:"download"`
+	Total    Percentiles `json:"total"`
+}
+
+/*
+SummarizeTimings computes nearest-rank percentiles of every timing phase
+over the given fetches.
+*/
+func SummarizeTimings(timings []models.Timing) TimingPercentiles {
+	phase := func(get func(models.Timing) float64) Percentiles {
+		values := make([]float64, len(timings))
+		for i, t := range timings {
+			values[i] = get(t)
+		}
+		sort.Float64s(values)
+		return Percentiles{
+			P50: percentile(values, 50),
+			P75: percentile(values, 75),
+			P90: percentile(values, 90),
+			P95: percentile(values, 95),
+			P99: percentile(values, 99),
+			Max: percentile(values, 100),
+		}
+	}
+	return TimingPercentiles{
+		Count:    len(timings),
+		DNS:      phase(func(t models.Timing) float64 { return t.DNSMs }),
+		Connect:  phase(func(t models.Timing) float64 { return t.ConnectMs }),
+		TLS:      phase(func(t models.Timing) float64 { return t.TLSMs }),
+		TTFB:     phase(func(t models.Timing) float64 { return t.TTFBMs }),
+		Download: phase(func(t models.Timing) float64 { return t.DownloadMs }),
+		Total:    phase(func(t models.Timing) float64 { return t.TotalMs }),
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted values (0 when empty).
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DMequanint/url-analyzer-pro/models"
+	"golang.org/x/net/publicsuffix"
+)
+
+// trackerEntry records one company/category match for a tracker domain.
+type trackerEntry struct {
+	Company  string
+	Category string
+}
+
+var (
+	// trackerDomains maps a domain to every tracker list entry that names it
+	trackerDomains = map[string][]trackerEntry{}
+
+	// trackerMu guards trackerDomains against concurrent reloads
+	trackerMu sync.RWMutex
+)
+
+/*
+LoadTrackerList reads a Disconnect-style tracker list from path and replaces
+the in-memory list used to categorize third-party hosts.
+
+The expected format is:
+
+	{
+		"categories": {
+			"Analytics": [
+				{ "Google": { "http://www.google.com/": ["google-analytics.com"] } }
+			],
+			"CDN": [ ... ]
+		}
+	}
+
+Category names are lowercased, so any category present in the file (for
+example a custom "CDN" section) is reported as-is.
+*/
+func LoadTrackerList(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var list struct {
+		Categories map[string][]map[string]map[string]json.RawMessage `json:"categories"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	// Keys are walked in sorted order so a domain listed more than once
+	// always gets its entries in the same order, whatever the map order
+	domains := map[string][]trackerEntry{}
+	for _, category := range sortedKeys(list.Categories) {
+		companies := list.Categories[category]
+		category = strings.ToLower(strings.TrimSpace(category))
+		for _, company := range companies {
+			for _, name := range sortedKeys(company) {
+				sites := company[name]
+				for _, site := range sortedKeys(sites) {
+					raw := sites[site]
+					// Besides domain arrays, entries may carry flags such as "dnt": "eff"
+					var hosts []string
+					if json.Unmarshal(raw, &hosts) != nil {
+						continue
+					}
+					for _, host := range hosts {
+						host = strings.ToLower(strings.TrimSpace(host))
+						if host != "" {
+							domains[host] = append(domains[host], trackerEntry{Company: name, Category: category})
+						}
+					}
+				}
+			}
+		}
+	}
+
+	trackerMu.Lock()
+	trackerDomains = domains
+	trackerMu.Unlock()
+	return nil
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/*
+lookupTracker returns the tracker list entries matching host or any of its
+parent domains, so "stats.g.doubleclick.net" matches "doubleclick.net".
+*/
+func lookupTracker(host string) []trackerEntry {
+	trackerMu.RLock()
+	defer trackerMu.RUnlock()
+
+	host = strings.ToLower(host)
+	for {
+		if entries, ok := trackerDomains[host]; ok {
+			return entries
+		}
+		dot := strings.IndexByte(host, '.')
+		if dot < 0 {
+			return nil
+		}
+		host = host[dot+1:]
+	}
+}
+
+/*
+registrableDomain returns the eTLD+1 of host (e.g. "bbc.co.uk" for
+"news.bbc.co.uk"), falling back to the host itself for IPs and
+single-label names.
+*/
+func registrableDomain(host string) string {
+	host = strings.ToLower(host)
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+/*
+buildThirdPartyInventory groups the page's links and subresources by host
+and returns every host whose registrable domain differs from pageHost,
+annotated with tracker list matches. The result is sorted by host.
+*/
+func buildThirdPartyInventory(pageHost string, links []string, resources []models.Resource) []models.ThirdPartyHost {
+	site := registrableDomain(pageHost)
+	hosts := map[string]*models.ThirdPartyHost{}
+
+	entry := func(host string) *models.ThirdPartyHost {
+		host = strings.ToLower(host)
+		if host == "" || registrableDomain(host) == site {
+			return nil
+		}
+		if h, ok := hosts[host]; ok {
+			return h
+		}
+		h := &models.ThirdPartyHost{Host: host, Domain: registrableDomain(host)}
+		categories := map[string]bool{}
+		for _, match := range lookupTracker(host) {
+			if h.Company == "" {
+				h.Company = match.Company
+			}
+			if !categories[match.Category] {
+				categories[match.Category] = true
+				h.Categories = append(h.Categories, match.Category)
+			}
+		}
+		sort.Strings(h.Categories)
+		hosts[host] = h
+		return h
+	}
+
+	for _, link := range links {
+		if h := entry(extractHost(link)); h != nil {
+			h.Links++
+		}
+	}
+	for _, r := range resources {
+		if h := entry(r.Host); h != nil {
+			h.Resources++
+		}
+	}
+
+	inventory := make([]models.ThirdPartyHost, 0, len(hosts))
+	for _, h := range hosts {
+		inventory = append(inventory, *h)
+	}
+	sort.Slice(inventory, func(i, j int) bool { return inventory[i].Host < inventory[j].Host })
+	return inventory
+}